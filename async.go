@@ -0,0 +1,155 @@
+package glog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy tells an asynchronous logger what to do with a record
+// when its queue is full.
+type OverflowPolicy int
+
+const (
+	Block      OverflowPolicy = iota // wait for room in the queue
+	DropNewest                       // discard the record being logged
+	DropOldest                       // discard the oldest queued record to make room
+)
+
+// asyncRecord is a formatted record, stamped t, waiting to be written to
+// w, or an Entry waiting to be handed to an EntryWriter.
+type asyncRecord struct {
+	w io.Writer
+	p *[]byte
+	t time.Time
+	e *Entry
+}
+
+var asyncBufPool = sync.Pool{
+	New: func() interface{} {
+		return new([]byte)
+	},
+}
+
+// asyncWriter writes formatted records on its own goroutine so that a slow
+// output does not hold up the goroutines that log.
+type asyncWriter struct {
+	queue   chan asyncRecord
+	policy  OverflowPolicy
+	dropped uint64
+	mu      sync.Mutex
+	idle    *sync.Cond
+	pending int
+	done    chan struct{}
+}
+
+func newAsyncWriter(queueSize int, policy OverflowPolicy) *asyncWriter {
+	a := &asyncWriter{
+		queue:  make(chan asyncRecord, queueSize),
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	a.idle = sync.NewCond(&a.mu)
+	go a.run()
+	return a
+}
+
+// enqueue copies p, a record stamped t, and queues it for writing to w.
+func (a *asyncWriter) enqueue(w io.Writer, p []byte, t time.Time) {
+	buf := asyncBufPool.Get().(*[]byte)
+	*buf = append((*buf)[:0], p...)
+	a.push(asyncRecord{w: w, p: buf, t: t})
+}
+
+// enqueueEntry copies e and queues it for w.
+func (a *asyncWriter) enqueueEntry(w EntryWriter, e *Entry) {
+	c := newEntry()
+	*c = *e
+	a.push(asyncRecord{w: w, e: c})
+}
+
+// push queues rec, applying the overflow policy if the queue is full.
+func (a *asyncWriter) push(rec asyncRecord) {
+	a.mu.Lock()
+	a.pending++
+	a.mu.Unlock()
+	switch a.policy {
+	case DropNewest:
+		select {
+		case a.queue <- rec:
+		default:
+			a.drop(rec)
+		}
+	case DropOldest:
+		for {
+			select {
+			case a.queue <- rec:
+				return
+			default:
+			}
+			select {
+			case old := <-a.queue:
+				a.drop(old)
+			default:
+			}
+		}
+	default:
+		a.queue <- rec
+	}
+}
+
+func (a *asyncWriter) run() {
+	defer close(a.done)
+	for rec := range a.queue {
+		var err error
+		if rec.e != nil {
+			err = rec.w.(EntryWriter).WriteEntry(rec.e)
+		} else if tw, ok := rec.w.(timedWriter); ok {
+			_, err = tw.writeAt(*rec.p, rec.t)
+		} else {
+			_, err = rec.w.Write(*rec.p)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "glog: async write failed, error: %v\n", err)
+		}
+		a.finish(rec)
+	}
+}
+
+func (a *asyncWriter) drop(rec asyncRecord) {
+	atomic.AddUint64(&a.dropped, 1)
+	a.finish(rec)
+}
+
+func (a *asyncWriter) finish(rec asyncRecord) {
+	if rec.e != nil {
+		rec.e.release()
+	} else {
+		asyncBufPool.Put(rec.p)
+	}
+	a.mu.Lock()
+	a.pending--
+	if a.pending == 0 {
+		a.idle.Broadcast()
+	}
+	a.mu.Unlock()
+}
+
+// flush waits until every queued record has been written or dropped.
+func (a *asyncWriter) flush() {
+	a.mu.Lock()
+	for a.pending > 0 {
+		a.idle.Wait()
+	}
+	a.mu.Unlock()
+}
+
+// close flushes the queue and stops the writing goroutine.
+func (a *asyncWriter) close() {
+	a.flush()
+	close(a.queue)
+	<-a.done
+}
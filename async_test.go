@@ -0,0 +1,105 @@
+package glog
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// gatedWriter blocks every write until its gate is opened.
+type gatedWriter struct {
+	gate chan struct{}
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gatedWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// closeRecorder records what had been written to w when it was closed.
+type closeRecorder struct {
+	w       *gatedWriter
+	written string
+}
+
+func (c *closeRecorder) Write(p []byte) (int, error) { return len(p), nil }
+
+func (c *closeRecorder) Close() error {
+	c.written = c.w.String()
+	return nil
+}
+
+func TestAsyncFlushAndClose(t *testing.T) {
+	w := &gatedWriter{gate: make(chan struct{})}
+	closer := &closeRecorder{w: w}
+	l := New(w, WithFlags(0), WithAsync(16, Block), WithWriteCloser(closer))
+	l.Info("one")
+	l.Info("two")
+	if got := w.String(); got != "" {
+		t.Errorf("async: nothing should be written yet, got %q", got)
+	}
+	close(w.gate)
+	l.Flush()
+	if got, want := w.String(), "one\ntwo\n"; got != want {
+		t.Errorf("flush: expected %q, got %q", want, got)
+	}
+	l.Info("three")
+	l.Close()
+	if got, want := closer.written, "one\ntwo\nthree\n"; got != want {
+		t.Errorf("close: closers ran before the queue drained, saw %q", got)
+	}
+}
+
+func TestAsyncOverflow(t *testing.T) {
+	for _, testcase := range []struct {
+		name   string
+		policy OverflowPolicy
+		want   string
+	}{
+		{"drop newest", DropNewest, "0\n1\n2\n"},
+		{"drop oldest", DropOldest, "0\n4\n5\n"},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			w := &gatedWriter{gate: make(chan struct{})}
+			l := New(w, WithFlags(0), WithAsync(2, testcase.policy))
+			l.Info("0")
+			// Wait for the writer goroutine to pick up "0" and block on the gate,
+			// leaving the two queue slots for the records below.
+			for {
+				l.mu.Lock()
+				n := len(l.async.queue)
+				l.mu.Unlock()
+				if n == 0 {
+					break
+				}
+			}
+			for _, s := range []string{"1", "2", "3", "4", "5"} {
+				l.Info(s)
+			}
+			close(w.gate)
+			l.Flush()
+			if got := w.String(); got != testcase.want {
+				t.Errorf("output: expected %q, got %q", testcase.want, got)
+			}
+			if got := l.Dropped(); got != 3 {
+				t.Errorf("dropped: expected 3, got %d", got)
+			}
+			l.Close()
+			l.Info("sync")
+			if got := w.String(); !strings.HasSuffix(got, "sync\n") {
+				t.Errorf("after close: expected a synchronous write, got %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package glog
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// colorMode is how the environment overrides terminal detection.
+type colorMode int
+
+const (
+	colorAuto   colorMode = iota // color terminals only
+	colorNever                   // NO_COLOR or FORCE_COLOR=0
+	colorAlways                  // FORCE_COLOR
+)
+
+// colorEnv is read once since the environment is consulted for every record.
+var colorEnv = colorFromEnv(os.Getenv)
+
+// colorFromEnv honors FORCE_COLOR, then NO_COLOR (https://no-color.org).
+func colorFromEnv(getenv func(string) string) colorMode {
+	if force := getenv("FORCE_COLOR"); force != "" {
+		switch strings.ToLower(force) {
+		case "0", "false":
+			return colorNever
+		}
+		return colorAlways
+	}
+	if getenv("NO_COLOR") != "" {
+		return colorNever
+	}
+	return colorAuto
+}
+
+// terminals caches whether an *os.File is a terminal.
+var terminals sync.Map // *os.File -> bool
+
+// isTerminal reports whether w is a terminal. Writers other than *os.File,
+// including io.MultiWriter, never are.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	if v, ok := terminals.Load(f); ok {
+		return v.(bool)
+	}
+	tty := isatty(f)
+	terminals.Store(f, tty)
+	return tty
+}
+
+// colorLocked returns the color of level on w, or "" when Lcolor and
+// Lcolorline are unset or w is not to be colored.
+func (l *Logger) colorLocked(w io.Writer, level Level) string {
+	if l.flag&(Lcolor|Lcolorline) == 0 || colorEnv == colorNever {
+		return ""
+	}
+	if colorEnv == colorAuto && !isTerminal(w) {
+		return ""
+	}
+	if c, ok := l.palette[level]; ok {
+		return c
+	}
+	if info, ok := level.info(); ok {
+		return info.color
+	}
+	return ""
+}
+
+// SetColor overrides the color of level with ANSI SGR parameters, such as
+// "1;32" for bold green. An empty sgr turns color off for level.
+func (l *Logger) SetColor(level Level, sgr string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.palette == nil {
+		l.palette = make(map[Level]string)
+	}
+	l.palette[level] = sgr
+}
+
+// ResetColor restores the default color of level.
+func (l *Logger) ResetColor(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.palette, level)
+}
+
+// appendColor writes the escape sequence selecting sgr.
+func appendColor(buf *[]byte, sgr string) {
+	*buf = append(*buf, "\x1b["...)
+	*buf = append(*buf, sgr...)
+	*buf = append(*buf, 'm')
+}
+
+// appendReset writes the escape sequence resetting colors.
+func appendReset(buf *[]byte) {
+	*buf = append(*buf, "\x1b[0m"...)
+}
+
+// SetColor overrides the color of level on the standard logger.
+func SetColor(level Level, sgr string) {
+	glog.SetColor(level, sgr)
+}
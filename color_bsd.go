@@ -0,0 +1,7 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package glog
+
+import "syscall"
+
+const ioctlGetTermios = syscall.TIOCGETA
@@ -0,0 +1,5 @@
+package glog
+
+import "syscall"
+
+const ioctlGetTermios = syscall.TCGETS
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package glog
+
+import "os"
+
+// isatty reports whether f is a character device, as terminals cannot be
+// told apart from other devices on this system.
+func isatty(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
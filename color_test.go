@@ -0,0 +1,86 @@
+package glog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestColorFromEnv(t *testing.T) {
+	for _, tc := range []struct {
+		env  map[string]string
+		want colorMode
+	}{
+		{map[string]string{}, colorAuto},
+		{map[string]string{"NO_COLOR": "1"}, colorNever},
+		{map[string]string{"FORCE_COLOR": "1", "NO_COLOR": "1"}, colorAlways},
+		{map[string]string{"FORCE_COLOR": "false"}, colorNever},
+	} {
+		getenv := func(key string) string { return tc.env[key] }
+		if got := colorFromEnv(getenv); got != tc.want {
+			t.Errorf("color env %v: expected %d, got %d", tc.env, tc.want, got)
+		}
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	f, err := ioutil.TempFile("", "glog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if isTerminal(f) || isTerminal(&bytes.Buffer{}) {
+		t.Errorf("isTerminal: a file and a buffer are not terminals")
+	}
+
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	if isTerminal(null) {
+		t.Errorf("isTerminal: %s is not a terminal", os.DevNull)
+	}
+}
+
+func forceColor(t *testing.T, mode colorMode) {
+	old := colorEnv
+	colorEnv = mode
+	t.Cleanup(func() { colorEnv = old })
+}
+
+func TestColor(t *testing.T) {
+	forceColor(t, colorAlways)
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lmsglevel|Lcolor), WithColor(WARNING, "1;33"))
+	l.Info("info")
+	l.Warning("warning")
+	l.SetColor(INFO, "")
+	l.Info("plain")
+	want := "\x1b[32m[INFO]\x1b[0m info\n\x1b[1;33m[WARNING]\x1b[0m warning\n[INFO] plain\n"
+	if got := buf.String(); got != want {
+		t.Errorf("color: expected %q, got %q", want, got)
+	}
+
+	buf.Reset()
+	l.ResetColor(INFO)
+	l.SetFlags(Lmsglevel | Lcolorline)
+	l.WithField("k", "v").Info("line\n")
+	if got, want := buf.String(), "\x1b[32m[INFO] line k=v\x1b[0m\n"; got != want {
+		t.Errorf("color line: expected %q, got %q", want, got)
+	}
+}
+
+func TestColorAuto(t *testing.T) {
+	forceColor(t, colorAuto)
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lmsglevel|Lcolor))
+	l.Info("info")
+	forceColor(t, colorNever)
+	l.Info("never")
+	if got, want := buf.String(), "[INFO] info\n[INFO] never\n"; got != want {
+		t.Errorf("color auto: expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package glog
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// isatty reports whether f is a terminal, by asking for its attributes as
+// isatty(3) does. Character devices such as /dev/null are not terminals.
+func isatty(f *os.File) bool {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		var termios syscall.Termios
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGetTermios, uintptr(unsafe.Pointer(&termios)))
+	})
+	return err == nil && errno == 0
+}
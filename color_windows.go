@@ -0,0 +1,20 @@
+package glog
+
+import (
+	"os"
+	"syscall"
+)
+
+// isatty reports whether f is a console. The NUL device is not.
+func isatty(f *os.File) bool {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var mode uint32
+	var cerr error
+	err = conn.Control(func(fd uintptr) {
+		cerr = syscall.GetConsoleMode(syscall.Handle(fd), &mode)
+	})
+	return err == nil && cerr == nil
+}
@@ -0,0 +1,97 @@
+package glog
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	program  = filepath.Base(os.Args[0])
+	host     = "unknownhost"
+	userName = "unknownuser"
+)
+
+func init() {
+	if h, err := os.Hostname(); err == nil {
+		host = shortHostname(h)
+	}
+	if u, err := user.Current(); err == nil {
+		userName = u.Username
+	}
+	// Sanitize userName since it may contain filepath separators on Windows.
+	userName = strings.Replace(userName, `\`, "_", -1)
+}
+
+// shortHostname returns its argument, truncating at the first period.
+func shortHostname(hostname string) string {
+	if i := strings.IndexByte(hostname, '.'); i >= 0 {
+		return hostname[:i]
+	}
+	return hostname
+}
+
+// logName returns the name of a new log file for s, and the name of the
+// symlink pointing at it, following golang/glog:
+// program.host.user.log.SEVERITY.yyyymmdd-hhmmss.pid and program.SEVERITY.
+func logName(s severity, t time.Time) (name, link string) {
+	name = fmt.Sprintf("%s.%s.%s.log.%s.%04d%02d%02d-%02d%02d%02d.%d",
+		program, host, userName, severityName[s],
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), pid)
+	return name, program + "." + severityName[s]
+}
+
+// logFile is the log file of a severity. It is created on the first write
+// so that severities never logged leave no empty file behind.
+type logFile struct {
+	mu   sync.Mutex
+	s    severity
+	file *os.File
+}
+
+func newLogFile(s severity) *logFile {
+	return &logFile{s: s}
+}
+
+func (f *logFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		if err := f.create(time.Now()); err != nil {
+			return 0, err
+		}
+	}
+	return f.file.Write(p)
+}
+
+func (f *logFile) create(t time.Time) error {
+	dir := logDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	name, link := logName(f.s, t)
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("glog: cannot create log: %v", err)
+	}
+	f.file = file
+	symlink := filepath.Join(dir, link)
+	os.Remove(symlink)        // ignore err
+	os.Symlink(name, symlink) // ignore err
+	return nil
+}
+
+func (f *logFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
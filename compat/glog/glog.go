@@ -0,0 +1,339 @@
+/*
+Package glog is a drop-in replacement for the API of github.com/golang/glog
+backed by a github.com/CodyGuo/glog Logger, so that code written against
+golang/glog only needs its import path changed:
+
+	import "github.com/CodyGuo/glog/compat/glog"
+
+	glog.Info("hello info")
+	glog.V(2).Infof("hello verbose %d", 2)
+
+Like golang/glog it registers the -v, -vmodule, -logtostderr,
+-alsologtostderr, -stderrthreshold and -log_dir flags on flag.CommandLine,
+which must be parsed before the first record is logged. Records use the
+golang/glog header:
+
+	Lmmdd hh:mm:ss.uuuuuu pid file:line] msg
+
+The backing Logger is built from the flags on first use; Logger returns it
+and SetLogger replaces it.
+*/
+package glog
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	base "github.com/CodyGuo/glog"
+)
+
+// severity is the severity of golang/glog: INFO, WARNING, ERROR or FATAL.
+type severity int32
+
+const (
+	infoLog severity = iota
+	warningLog
+	errorLog
+	fatalLog
+	numSeverity
+)
+
+var severityName = [numSeverity]string{"INFO", "WARNING", "ERROR", "FATAL"}
+
+var severityLevel = [numSeverity]base.Level{base.INFO, base.WARNING, base.ERROR, base.FATAL}
+
+// Get is part of the flag.Getter interface.
+func (s *severity) Get() interface{} {
+	return *s
+}
+
+// Set is part of the flag.Value interface. It accepts a severity name or
+// its number.
+func (s *severity) Set(value string) error {
+	for i, name := range severityName {
+		if strings.EqualFold(value, name) {
+			*s = severity(i)
+			return nil
+		}
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < 0 || v >= int(numSeverity) {
+		return errors.New("glog: unknown severity " + value)
+	}
+	*s = severity(v)
+	return nil
+}
+
+func (s *severity) String() string {
+	if *s < 0 || *s >= numSeverity {
+		return strconv.Itoa(int(*s))
+	}
+	return severityName[*s]
+}
+
+var (
+	toStderr        bool
+	alsoToStderr    bool
+	stderrThreshold = errorLog
+	logDir          string
+)
+
+func init() {
+	flag.BoolVar(&toStderr, "logtostderr", false, "log to standard error instead of files")
+	flag.BoolVar(&alsoToStderr, "alsologtostderr", false, "log to standard error as well as files")
+	flag.Var(&verbosity, "v", "log level for V logs")
+	flag.Var(&stderrThreshold, "stderrthreshold", "logs at or above this threshold go to stderr")
+	flag.Var(&vmodule, "vmodule", "comma-separated list of pattern=N settings for file-filtered logging")
+	flag.StringVar(&logDir, "log_dir", "", "If non-empty, write log files in this directory")
+}
+
+var (
+	mu  sync.Mutex
+	std atomic.Pointer[base.Logger]
+)
+
+// current returns the backing Logger, or nil if it has not been built yet.
+func current() *base.Logger {
+	return std.Load()
+}
+
+// Logger returns the Logger backing the package, building it from the
+// flags if needed.
+func Logger() *base.Logger {
+	if l := std.Load(); l != nil {
+		return l
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	l := std.Load()
+	if l == nil {
+		l = newLogger()
+		setLogger(l)
+	}
+	return l
+}
+
+// SetLogger replaces the Logger backing the package. Records are written
+// through it at depth 1 so that file and line point at the callers of this
+// package. The -v and -vmodule flags are applied to it.
+func SetLogger(l *base.Logger) {
+	mu.Lock()
+	defer mu.Unlock()
+	setLogger(l)
+}
+
+func setLogger(l *base.Logger) {
+	if l == nil {
+		std.Store(nil)
+		return
+	}
+	l.SetVerbosity(int(verbosity.Get().(Level)))
+	l.SetVModule(vmodule.String()) // validated by the flag
+	std.Store(l)
+}
+
+// newLogger builds a Logger following the flags. Standard error and the
+// log files are leveled outputs, so the output of the Logger itself is unused
+// unless -logtostderr is set.
+func newLogger() *base.Logger {
+	options := []base.Option{base.WithFlags(base.Lshortfile), base.WithFormatter(formatter{})}
+	if toStderr {
+		return base.New(os.Stderr, append(options, base.WithLevel(base.INFO))...)
+	}
+	l := base.New(base.Discard, append(options, base.WithLevel(base.PANIC))...)
+	threshold := stderrThreshold
+	if alsoToStderr {
+		threshold = infoLog
+	}
+	if threshold >= 0 && threshold < numSeverity {
+		l.AddLeveledOutput(os.Stderr, severityLevel[threshold], nil)
+	}
+	for s := infoLog; s < numSeverity; s++ {
+		l.AddLeveledWriteCloser(newLogFile(s), severityLevel[s], nil)
+	}
+	return l
+}
+
+// formatter lays out records like golang/glog.
+type formatter struct{}
+
+func (formatter) Format(e *base.Entry, buf *[]byte) error {
+	name := e.Level.String()
+	t := e.Time
+	_, month, day := t.Date()
+	hour, min, sec := t.Clock()
+	file := e.File
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		file = file[i+1:]
+	}
+	*buf = append(*buf, fmt.Sprintf("%c%02d%02d %02d:%02d:%02d.%06d %7d %s:%d] ",
+		name[0], int(month), day, hour, min, sec, t.Nanosecond()/1e3, pid, file, e.Line)...)
+	*buf = append(*buf, e.Message...)
+	if len(e.Fields) > 0 || len(e.Stack) > 0 {
+		if n := len(*buf); n > 0 && (*buf)[n-1] == '\n' {
+			*buf = (*buf)[:n-1]
+		}
+		appendFields(buf, e.Fields)
+		appendStack(buf, e.Stack)
+	}
+	return nil
+}
+
+// appendFields writes fields as " key=value" pairs sorted by key, as the
+// TextFormatter does.
+func appendFields(buf *[]byte, fields base.Fields) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := fmt.Sprint(fields[k])
+		if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			v = strconv.Quote(v)
+		}
+		*buf = append(*buf, ' ')
+		*buf = append(*buf, k...)
+		*buf = append(*buf, '=')
+		*buf = append(*buf, v...)
+	}
+}
+
+// appendStack writes one function and file:line pair per frame of stack.
+func appendStack(buf *[]byte, stack []base.Frame) {
+	for _, f := range stack {
+		*buf = append(*buf, "\n\t"...)
+		*buf = append(*buf, f.Function...)
+		*buf = append(*buf, "\n\t\t"...)
+		*buf = append(*buf, f.File...)
+		*buf = append(*buf, ':')
+		*buf = strconv.AppendInt(*buf, int64(f.Line), 10)
+	}
+}
+
+var pid = os.Getpid()
+
+// output writes a record for the caller depth frames above the caller of
+// the function calling output.
+func output(depth int, s severity, format string, args []interface{}) {
+	l := Logger()
+	if format == "" {
+		l.OutputDepth(depth+2, severityLevel[s], "%s", fmt.Sprint(args...))
+	} else {
+		l.OutputDepth(depth+2, severityLevel[s], format, args...)
+	}
+}
+
+func outputln(depth int, s severity, args []interface{}) {
+	Logger().OutputDepth(depth+2, severityLevel[s], "%s", fmt.Sprintln(args...))
+}
+
+// Flush flushes the pending records of the backing Logger.
+func Flush() {
+	Logger().Flush()
+}
+
+// exit closes the backing Logger and exits with code.
+func exit(code int) {
+	l := Logger()
+	l.Flush()
+	l.Close()
+	os.Exit(code)
+}
+
+func Info(args ...interface{}) {
+	output(0, infoLog, "", args)
+}
+
+func InfoDepth(depth int, args ...interface{}) {
+	output(depth, infoLog, "", args)
+}
+
+func Infoln(args ...interface{}) {
+	outputln(0, infoLog, args)
+}
+
+func Infof(format string, args ...interface{}) {
+	output(0, infoLog, format, args)
+}
+
+func Warning(args ...interface{}) {
+	output(0, warningLog, "", args)
+}
+
+func WarningDepth(depth int, args ...interface{}) {
+	output(depth, warningLog, "", args)
+}
+
+func Warningln(args ...interface{}) {
+	outputln(0, warningLog, args)
+}
+
+func Warningf(format string, args ...interface{}) {
+	output(0, warningLog, format, args)
+}
+
+func Error(args ...interface{}) {
+	output(0, errorLog, "", args)
+}
+
+func ErrorDepth(depth int, args ...interface{}) {
+	output(depth, errorLog, "", args)
+}
+
+func Errorln(args ...interface{}) {
+	outputln(0, errorLog, args)
+}
+
+func Errorf(format string, args ...interface{}) {
+	output(0, errorLog, format, args)
+}
+
+// Fatal logs at FATAL and exits with status 255, as golang/glog does.
+func Fatal(args ...interface{}) {
+	output(0, fatalLog, "", args)
+	exit(255)
+}
+
+func FatalDepth(depth int, args ...interface{}) {
+	output(depth, fatalLog, "", args)
+	exit(255)
+}
+
+func Fatalln(args ...interface{}) {
+	outputln(0, fatalLog, args)
+	exit(255)
+}
+
+func Fatalf(format string, args ...interface{}) {
+	output(0, fatalLog, format, args)
+	exit(255)
+}
+
+// Exit logs at FATAL and exits with status 1.
+func Exit(args ...interface{}) {
+	output(0, fatalLog, "", args)
+	exit(1)
+}
+
+func ExitDepth(depth int, args ...interface{}) {
+	output(depth, fatalLog, "", args)
+	exit(1)
+}
+
+func Exitln(args ...interface{}) {
+	outputln(0, fatalLog, args)
+	exit(1)
+}
+
+func Exitf(format string, args ...interface{}) {
+	output(0, fatalLog, format, args)
+	exit(1)
+}
@@ -0,0 +1,131 @@
+package glog
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	base "github.com/CodyGuo/glog"
+)
+
+// capture backs the package by a Logger writing to a buffer, as the flags
+// would with -logtostderr, and restores the default Logger afterwards.
+func capture(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	SetLogger(base.New(&buf, base.WithFlags(base.Lshortfile), base.WithFormatter(formatter{})))
+	t.Cleanup(func() { SetLogger(nil) })
+	return &buf
+}
+
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+	f := flag.Lookup(name)
+	if f == nil {
+		t.Fatalf("flag -%s is not registered", name)
+	}
+	old := f.Value.String()
+	if err := flag.Set(name, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { flag.Set(name, old) })
+}
+
+func TestHeader(t *testing.T) {
+	buf := capture(t)
+	Infoln("hello", "world")
+	Warningf("hello %d", 2)
+	re := regexp.MustCompile(`^I\d{4} \d\d:\d\d:\d\d\.\d{6} +\d+ glog_test.go:41\] hello world
+W\d{4} \d\d:\d\d:\d\d\.\d{6} +\d+ glog_test.go:42\] hello 2
+$`)
+	if got := buf.String(); !re.MatchString(got) {
+		t.Errorf("header: unexpected %q", got)
+	}
+}
+
+func TestV(t *testing.T) {
+	buf := capture(t)
+	setFlag(t, "v", "1")
+	V(1).Info("v1")
+	V(2).Info("v2")
+	setFlag(t, "vmodule", "glog_te*=3,other=9")
+	V(3).Infof("%s", "vmodule")
+	V(4).Info("v4")
+	got := buf.String()
+	if !strings.Contains(got, "] v1\n") || !strings.Contains(got, "] vmodule\n") ||
+		strings.Contains(got, "v2") || strings.Contains(got, "v4") {
+		t.Errorf("V: unexpected %q", got)
+	}
+}
+
+func TestVmoduleFlag(t *testing.T) {
+	setFlag(t, "vmodule", "http/server.go=2,db*=3")
+	if err := flag.Set("vmodule", "a=x"); err == nil {
+		t.Error("vmodule: expected an error")
+	}
+	if got := flag.Lookup("vmodule").Value.String(); got != "http/server.go=2,db*=3" {
+		t.Errorf("vmodule: unexpected value %q", got)
+	}
+}
+
+func TestFatalStack(t *testing.T) {
+	buf := capture(t)
+	Logger().WithFields(base.Fields{"user": "ann smith"}).OutputDepth(0, base.FATAL, "fatal")
+	got := buf.String()
+	if !strings.Contains(got, "] fatal user=\"ann smith\"\n\t") {
+		t.Errorf("fatal: expected the fields before the stack, got %q", got)
+	}
+	if !strings.Contains(got, ".TestFatalStack\n\t\t") {
+		t.Errorf("fatal: expected the stack from TestFatalStack, got %q", got)
+	}
+}
+
+func TestLogDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	setFlag(t, "log_dir", dir)
+	setFlag(t, "stderrthreshold", "FATAL")
+	SetLogger(nil)
+	defer SetLogger(nil)
+	Info("info")
+	Error("error")
+	Logger().Close()
+
+	info, err := ioutil.ReadFile(filepath.Join(dir, program+".INFO"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(info), "\n"); n != 2 {
+		t.Errorf("INFO file: expected 2 records, got %q", info)
+	}
+	errs, err := ioutil.ReadFile(filepath.Join(dir, program+".ERROR"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(errs), "E") || strings.Contains(string(errs), "] info") {
+		t.Errorf("ERROR file: unexpected %q", errs)
+	}
+	if _, err := os.Stat(filepath.Join(dir, program+".FATAL")); !os.IsNotExist(err) {
+		t.Errorf("FATAL file should not exist, stat error: %v", err)
+	}
+}
+
+func TestSeverityFlag(t *testing.T) {
+	var s severity
+	for value, want := range map[string]severity{"warning": warningLog, "2": errorLog, "FATAL": fatalLog} {
+		if err := s.Set(value); err != nil || s != want {
+			t.Errorf("severity %q: expected %v, got %v (%v)", value, want, s, err)
+		}
+	}
+	if err := s.Set("7"); err == nil {
+		t.Errorf("severity 7: expected an error")
+	}
+}
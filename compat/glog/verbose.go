@@ -0,0 +1,109 @@
+package glog
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	base "github.com/CodyGuo/glog"
+)
+
+// Level is a verbosity level, as set by the -v flag and compared by V.
+type Level int32
+
+// Get is part of the flag.Getter interface.
+func (l *Level) Get() interface{} {
+	return Level(atomic.LoadInt32((*int32)(l)))
+}
+
+// Set is part of the flag.Value interface. It also applies to the backing
+// Logger if it has been built already.
+func (l *Level) Set(value string) error {
+	v, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32((*int32)(l), int32(v))
+	if l == &verbosity {
+		if std := current(); std != nil {
+			std.SetVerbosity(int(v))
+		}
+	}
+	return nil
+}
+
+func (l *Level) String() string {
+	return strconv.FormatInt(int64(atomic.LoadInt32((*int32)(l))), 10)
+}
+
+var verbosity Level
+
+// moduleSpec is the value of the -vmodule flag, applied to the backing
+// Logger with SetVModule.
+type moduleSpec struct {
+	value atomic.Value // string
+}
+
+var vmodule moduleSpec
+
+// Get is part of the flag.Getter interface.
+func (m *moduleSpec) Get() interface{} {
+	return nil
+}
+
+// Set is part of the flag.Value interface. value is a comma-separated list
+// of pattern=N, as accepted by (*Logger).SetVModule.
+func (m *moduleSpec) Set(value string) error {
+	std := current()
+	if std == nil {
+		// Validate against a scratch Logger until the backing one is built.
+		std = base.New(base.Discard)
+	}
+	if err := std.SetVModule(value); err != nil {
+		return err
+	}
+	m.value.Store(value)
+	return nil
+}
+
+func (m *moduleSpec) String() string {
+	s, _ := m.value.Load().(string)
+	return s
+}
+
+// Verbose is returned by V. Its methods log at INFO when it is true and do
+// nothing otherwise, so that
+//
+//	glog.V(2).Info("hello")
+//
+// costs a comparison when verbosity is below 2.
+type Verbose bool
+
+// V reports whether verbosity at the call site is at least level, from -v
+// or from the first -vmodule pattern matching the file of the caller.
+func V(level Level) Verbose {
+	return Verbose(Logger().VDepth(1, int(level)).Enabled())
+}
+
+func (v Verbose) Info(args ...interface{}) {
+	if v {
+		output(0, infoLog, "", args)
+	}
+}
+
+func (v Verbose) InfoDepth(depth int, args ...interface{}) {
+	if v {
+		output(depth, infoLog, "", args)
+	}
+}
+
+func (v Verbose) Infoln(args ...interface{}) {
+	if v {
+		outputln(0, infoLog, args)
+	}
+}
+
+func (v Verbose) Infof(format string, args ...interface{}) {
+	if v {
+		output(0, infoLog, format, args)
+	}
+}
@@ -0,0 +1,174 @@
+package glog
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"sync"
+)
+
+// A Compressor compresses rotated log files. Gzip is built in; other
+// formats such as zstd can be plugged in by implementing this interface
+// around their encoder.
+type Compressor interface {
+	// Extension is appended to the name of compressed files, e.g. ".gz".
+	Extension() string
+	// NewWriter returns a writer compressing to w. Closing it must flush
+	// the compressed stream but not close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// Gzip compresses rotated files to name.gz.
+var Gzip Compressor = gzipCompressor{}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Extension() string {
+	return ".gz"
+}
+
+func (gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+// Compress compresses files in the background once they have been rotated
+// out. Errors are reported on the Errors channel of the writer.
+func Compress(c Compressor) RotateOption {
+	return func(o *rotateOptions) {
+		o.compress = c
+	}
+}
+
+// compressFile compresses src to dst and removes src. dst is written under
+// a temporary name first so that a partial file never carries the final name.
+func compressFile(c Compressor, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	zw, err := c.NewWriter(out)
+	if err == nil {
+		_, err = io.Copy(zw, in)
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
+
+// errorBuffer is the capacity of the Errors channels of loggers and writers.
+const errorBuffer = 16
+
+// backgroundWorker runs tasks one after another on its own goroutine so
+// that slow file work never holds up the writer that queued it.
+type backgroundWorker struct {
+	mu     sync.Mutex
+	tasks  []func() error
+	wake   chan struct{}
+	errs   chan error
+	done   chan struct{}
+	closed bool
+}
+
+func newBackgroundWorker() *backgroundWorker {
+	w := &backgroundWorker{
+		wake: make(chan struct{}, 1),
+		errs: make(chan error, errorBuffer),
+		done: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// queue schedules task after those already queued. It never blocks.
+func (w *backgroundWorker) queue(task func() error) {
+	w.mu.Lock()
+	w.tasks = append(w.tasks, task)
+	w.mu.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *backgroundWorker) run() {
+	defer close(w.done)
+	defer close(w.errs)
+	for {
+		w.mu.Lock()
+		tasks := w.tasks
+		w.tasks = nil
+		closed := w.closed
+		w.mu.Unlock()
+		for _, task := range tasks {
+			if err := task(); err != nil {
+				w.report(err)
+			}
+		}
+		if len(tasks) > 0 {
+			continue
+		}
+		if closed {
+			return
+		}
+		<-w.wake
+	}
+}
+
+// report sends err on the errors channel of the worker.
+func (w *backgroundWorker) report(err error) {
+	reportError(w.errs, err)
+}
+
+// reportError sends err on errs, discarding the oldest error if nobody has
+// been reading it.
+func reportError(errs chan error, err error) {
+	for {
+		select {
+		case errs <- err:
+			return
+		default:
+		}
+		select {
+		case <-errs:
+		default:
+		}
+	}
+}
+
+// close waits for the queued tasks to finish and stops the worker.
+func (w *backgroundWorker) close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	w.mu.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+	<-w.done
+}
@@ -0,0 +1,202 @@
+package glog
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readGzip(t *testing.T, name string) string {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotatingFileCompress(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	name := filepath.Join(dir, "app.log")
+	r, err := NewRotatingFile(name, MaxSize(10), MaxBackups(2), Compress(Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(Discard, WithFlags(0), WithWriteCloser(r))
+	for _, s := range []string{"one", "two", "three", "four", "five", "six"} {
+		l.Info(s)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for err := range r.Errors() {
+		t.Errorf("background error: %v", err)
+	}
+
+	if got := readFile(t, name); got != "six\n" {
+		t.Errorf("current file: expected %q, got %q", "six\n", got)
+	}
+	if got := readGzip(t, name+".1.gz"); got != "four\nfive\n" {
+		t.Errorf("backup 1: expected %q, got %q", "four\nfive\n", got)
+	}
+	if got := readGzip(t, name+".2.gz"); got != "three\n" {
+		t.Errorf("backup 2: expected %q, got %q", "three\n", got)
+	}
+	files, _ := filepath.Glob(name + "*")
+	if len(files) != 3 {
+		t.Errorf("files: expected 3, got %v", files)
+	}
+}
+
+func TestTimeRotatingFileCompress(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	now := time.Date(2020, 4, 27, 0, 0, 0, 0, time.UTC)
+	r, err := NewTimeRotatingFile(filepath.Join(dir, "app-%Y%m%d.log"),
+		UTC(), Clock(func() time.Time { return now }), Compress(Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("monday\n"))
+	now = now.AddDate(0, 0, 1)
+	r.Write([]byte("tuesday\n"))
+	r.Close()
+	if got := readGzip(t, filepath.Join(dir, "app-20200427.log.gz")); got != "monday\n" {
+		t.Errorf("compressed file: expected %q, got %q", "monday\n", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app-20200427.log")); !os.IsNotExist(err) {
+		t.Errorf("uncompressed file should have been removed, stat error: %v", err)
+	}
+}
+
+func TestTimeRotatingFileCompressSymlinkFailure(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	now := time.Date(2020, 4, 27, 0, 0, 0, 0, time.UTC)
+	link := filepath.Join(dir, "current")
+	r, err := NewTimeRotatingFile(filepath.Join(dir, "app-%Y%m%d.log"),
+		UTC(), Clock(func() time.Time { return now }), Symlink(link), Compress(Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(link)
+	if err := os.MkdirAll(filepath.Join(link, "busy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("monday\n"))
+	now = now.AddDate(0, 0, 1)
+	r.Write([]byte("tuesday\n"))
+	r.Close()
+	var errs []error
+	for err := range r.Errors() {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !os.IsExist(errs[0]) {
+		t.Errorf("errors: expected the symlink error alone, got %v", errs)
+	}
+	if got := readGzip(t, filepath.Join(dir, "app-20200427.log.gz")); got != "monday\n" {
+		t.Errorf("compressed file: expected %q, got %q", "monday\n", got)
+	}
+}
+
+// blockingCompressor is Gzip holding every compression until release is
+// closed, and signalling started when one is waiting.
+type blockingCompressor struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c blockingCompressor) Extension() string { return Gzip.Extension() }
+
+func (c blockingCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	select {
+	case c.started <- struct{}{}:
+	default:
+	}
+	<-c.release
+	return Gzip.NewWriter(w)
+}
+
+func TestRotatingFileCompressPending(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	name := filepath.Join(dir, "app.log")
+	now := time.Date(2020, 4, 27, 12, 0, 0, 0, time.UTC)
+	c := blockingCompressor{started: make(chan struct{}, 1), release: make(chan struct{})}
+	r, err := NewRotatingFile(name, TimestampBackups(), UTC(), MaxBackups(2), Compress(c),
+		Clock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first backup is held in compression while three more wait for it.
+	for i, s := range []string{"one\n", "two\n", "three\n", "four\n"} {
+		r.Write([]byte(s))
+		now = now.Add(time.Second)
+		if err := r.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			<-c.started
+		}
+	}
+	close(c.release)
+	r.Close()
+	for err := range r.Errors() {
+		t.Errorf("background error: %v", err)
+	}
+	for _, b := range []struct{ suffix, want string }{
+		{".20200427-120003.000.gz", "three\n"},
+		{".20200427-120004.000.gz", "four\n"},
+	} {
+		if got := readGzip(t, name+b.suffix); got != b.want {
+			t.Errorf("backup %s: expected %q, got %q", b.suffix, b.want, got)
+		}
+	}
+	files, _ := filepath.Glob(name + ".*")
+	if len(files) != 2 {
+		t.Errorf("backups: expected 2, got %v", files)
+	}
+}
+
+type failingCompressor struct{}
+
+func (failingCompressor) Extension() string { return ".fail" }
+
+func (failingCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return nil, errors.New("compressor unavailable")
+}
+
+func TestCompressErrors(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	name := filepath.Join(dir, "app.log")
+	r, err := NewRotatingFile(name, Compress(failingCompressor{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("lost?\n"))
+	r.Rotate()
+	r.Close()
+	var errs []error
+	for err := range r.Errors() {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || errs[0].Error() != "compressor unavailable" {
+		t.Errorf("errors: expected [compressor unavailable], got %v", errs)
+	}
+}
@@ -0,0 +1,246 @@
+package glog
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// maxHelperDepth bounds the frames walked past helper functions.
+const maxHelperDepth = 32
+
+// helperSet holds the names of the functions marked by Helper.
+type helperSet struct {
+	n     int32
+	names sync.Map
+}
+
+func (h *helperSet) empty() bool {
+	return atomic.LoadInt32(&h.n) == 0
+}
+
+func (h *helperSet) contains(function string) bool {
+	_, ok := h.names.Load(function)
+	return ok
+}
+
+func (h *helperSet) add(function string) {
+	if _, loaded := h.names.LoadOrStore(function, struct{}{}); !loaded {
+		atomic.AddInt32(&h.n, 1)
+	}
+}
+
+// Helper marks the calling function as a logging helper, like
+// testing.T.Helper: when file and line are printed, its frames are skipped
+// so that records point at the code calling the helper. It is safe to call
+// from several goroutines and affects l and the loggers sharing its output.
+func (l *Logger) Helper() {
+	var pcs [1]uintptr
+	if runtime.Callers(2, pcs[:]) == 0 {
+		return
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	l.helpers.add(frame.Function)
+}
+
+// Helper marks the calling function as a logging helper of the standard logger.
+func Helper() {
+	var pcs [1]uintptr
+	if runtime.Callers(2, pcs[:]) == 0 {
+		return
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	glog.helpers.add(frame.Function)
+}
+
+// OutputDepth writes a record at level without exiting or panicking, even
+// for FATAL and PANIC, attributed to the caller depth frames above the
+// caller of OutputDepth. It is meant for packages wrapping a Logger.
+func (l *Logger) OutputDepth(depth int, level Level, format string, v ...interface{}) error {
+	return l.output(depth-1, level, format, v)
+}
+
+func (l *Logger) logDepth(depth int, level Level, v ...interface{}) {
+	l.output(depth, level, "", v)
+}
+
+func (l *Logger) logfDepth(depth int, level Level, format string, v ...interface{}) {
+	l.output(depth, level, format, v)
+}
+
+// TraceDepth logs at TRACE like Trace, attributing the record to the caller
+// depth frames above the caller of TraceDepth. The other Depth functions
+// behave alike for their level.
+func (l *Logger) TraceDepth(depth int, v ...interface{}) {
+	l.logDepth(depth, TRACE, v...)
+}
+
+func (l *Logger) DebugDepth(depth int, v ...interface{}) {
+	l.logDepth(depth, DEBUG, v...)
+}
+
+func (l *Logger) InfoDepth(depth int, v ...interface{}) {
+	l.logDepth(depth, INFO, v...)
+}
+
+func (l *Logger) NoticeDepth(depth int, v ...interface{}) {
+	l.logDepth(depth, NOTICE, v...)
+}
+
+func (l *Logger) WarnDepth(depth int, v ...interface{}) {
+	l.logDepth(depth, WARNING, v...)
+}
+
+func (l *Logger) WarningDepth(depth int, v ...interface{}) {
+	l.logDepth(depth, WARNING, v...)
+}
+
+func (l *Logger) ErrorDepth(depth int, v ...interface{}) {
+	l.logDepth(depth, ERROR, v...)
+}
+
+func (l *Logger) CriticalDepth(depth int, v ...interface{}) {
+	l.logDepth(depth, CRITICAL, v...)
+}
+
+func (l *Logger) FatalDepth(depth int, v ...interface{}) {
+	l.logDepth(depth, FATAL, v...)
+	l.Close()
+	os.Exit(1)
+}
+
+func (l *Logger) PanicDepth(depth int, v ...interface{}) {
+	l.logDepth(depth, PANIC, v...)
+	l.Close()
+	panic(fmt.Sprint(v...))
+}
+
+func (l *Logger) TracefDepth(depth int, format string, v ...interface{}) {
+	l.logfDepth(depth, TRACE, format, v...)
+}
+
+func (l *Logger) DebugfDepth(depth int, format string, v ...interface{}) {
+	l.logfDepth(depth, DEBUG, format, v...)
+}
+
+func (l *Logger) InfofDepth(depth int, format string, v ...interface{}) {
+	l.logfDepth(depth, INFO, format, v...)
+}
+
+func (l *Logger) NoticefDepth(depth int, format string, v ...interface{}) {
+	l.logfDepth(depth, NOTICE, format, v...)
+}
+
+func (l *Logger) WarnfDepth(depth int, format string, v ...interface{}) {
+	l.logfDepth(depth, WARNING, format, v...)
+}
+
+func (l *Logger) WarningfDepth(depth int, format string, v ...interface{}) {
+	l.logfDepth(depth, WARNING, format, v...)
+}
+
+func (l *Logger) ErrorfDepth(depth int, format string, v ...interface{}) {
+	l.logfDepth(depth, ERROR, format, v...)
+}
+
+func (l *Logger) CriticalfDepth(depth int, format string, v ...interface{}) {
+	l.logfDepth(depth, CRITICAL, format, v...)
+}
+
+func (l *Logger) FatalfDepth(depth int, format string, v ...interface{}) {
+	l.logfDepth(depth, FATAL, format, v...)
+	l.Close()
+	os.Exit(1)
+}
+
+func (l *Logger) PanicfDepth(depth int, format string, v ...interface{}) {
+	l.logfDepth(depth, PANIC, format, v...)
+	l.Close()
+	panic(fmt.Sprintf(format, v...))
+}
+
+func OutputDepth(depth int, level Level, format string, v ...interface{}) error {
+	return glog.OutputDepth(depth, level, format, v...)
+}
+
+func TraceDepth(depth int, v ...interface{}) {
+	glog.TraceDepth(depth, v...)
+}
+
+func DebugDepth(depth int, v ...interface{}) {
+	glog.DebugDepth(depth, v...)
+}
+
+func InfoDepth(depth int, v ...interface{}) {
+	glog.InfoDepth(depth, v...)
+}
+
+func NoticeDepth(depth int, v ...interface{}) {
+	glog.NoticeDepth(depth, v...)
+}
+
+func WarnDepth(depth int, v ...interface{}) {
+	glog.WarnDepth(depth, v...)
+}
+
+func WarningDepth(depth int, v ...interface{}) {
+	glog.WarningDepth(depth, v...)
+}
+
+func ErrorDepth(depth int, v ...interface{}) {
+	glog.ErrorDepth(depth, v...)
+}
+
+func CriticalDepth(depth int, v ...interface{}) {
+	glog.CriticalDepth(depth, v...)
+}
+
+func FatalDepth(depth int, v ...interface{}) {
+	glog.FatalDepth(depth, v...)
+}
+
+func PanicDepth(depth int, v ...interface{}) {
+	glog.PanicDepth(depth, v...)
+}
+
+func TracefDepth(depth int, format string, v ...interface{}) {
+	glog.TracefDepth(depth, format, v...)
+}
+
+func DebugfDepth(depth int, format string, v ...interface{}) {
+	glog.DebugfDepth(depth, format, v...)
+}
+
+func InfofDepth(depth int, format string, v ...interface{}) {
+	glog.InfofDepth(depth, format, v...)
+}
+
+func NoticefDepth(depth int, format string, v ...interface{}) {
+	glog.NoticefDepth(depth, format, v...)
+}
+
+func WarnfDepth(depth int, format string, v ...interface{}) {
+	glog.WarnfDepth(depth, format, v...)
+}
+
+func WarningfDepth(depth int, format string, v ...interface{}) {
+	glog.WarningfDepth(depth, format, v...)
+}
+
+func ErrorfDepth(depth int, format string, v ...interface{}) {
+	glog.ErrorfDepth(depth, format, v...)
+}
+
+func CriticalfDepth(depth int, format string, v ...interface{}) {
+	glog.CriticalfDepth(depth, format, v...)
+}
+
+func FatalfDepth(depth int, format string, v ...interface{}) {
+	glog.FatalfDepth(depth, format, v...)
+}
+
+func PanicfDepth(depth int, format string, v ...interface{}) {
+	glog.PanicfDepth(depth, format, v...)
+}
@@ -0,0 +1,74 @@
+package glog
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func logViaHelper(l *Logger, s string) {
+	l.Helper()
+	l.Info(s)
+}
+
+func logViaNestedHelper(l *Logger, s string) {
+	l.Helper()
+	logViaHelper(l, s)
+}
+
+func logViaDepth(l *Logger, s string) {
+	l.InfoDepth(1, s)
+}
+
+func TestDepth(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lshortfile))
+	logViaDepth(l, "depth")
+	l.WarningfDepth(0, "%s", "depth 0")
+	if got, want := buf.String(), "depth_test.go:27: depth\ndepth_test.go:28: depth 0\n"; got != want {
+		t.Errorf("depth: expected %q, got %q", want, got)
+	}
+}
+
+func TestHelper(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lshortfile))
+	logViaHelper(l, "helper")
+	logViaNestedHelper(l.WithField("k", "v"), "nested")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{"depth_test.go:37: helper", "depth_test.go:38: nested k=v"}
+	if len(lines) != len(want) || lines[0] != want[0] || lines[1] != want[1] {
+		t.Errorf("helper: expected %q, got %q", want, lines)
+	}
+	if l.CallDepth() != 3 {
+		t.Errorf("helper: call depth changed to %d", l.CallDepth())
+	}
+}
+
+func TestPackageDepth(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stderr)
+	flags := Flags()
+	SetFlags(Lshortfile)
+	defer SetFlags(flags)
+	level, depth := GetLevel(), CallDepth()
+	SetLevel(INFO)
+	defer SetLevel(level)
+	SetCallDepth(4)
+	defer SetCallDepth(depth)
+	InfoDepth(0, "package")
+	if got, want := buf.String(), "depth_test.go:61: package\n"; got != want {
+		t.Errorf("package depth: expected %q, got %q", want, got)
+	}
+}
+
+func TestOutputDepth(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lshortfile|Lmsglevel))
+	l.OutputDepth(0, ERROR, "%s", "no exit")
+	if got, want := buf.String(), "depth_test.go:70: [ERROR] no exit\n"; got != want {
+		t.Errorf("output depth: expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,138 @@
+package glog
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An Entry is a single log record. It is handed to every extension point of
+// a Logger, starting with its Formatter.
+//
+// Entries are recycled once the record has been written, so an extension
+// point must not keep a reference to e after it returns.
+type Entry struct {
+	Logger  *Logger   // the logger the record was written through
+	Time    time.Time // when the record was created
+	Level   Level
+	PC      uintptr // program counter of the caller, 0 unless Llongfile, Lshortfile, Lfuncname or Lpackage is set
+	File    string  // full file name of the caller
+	Line    int     // line number of the caller
+	Prefix  string  // prefix of the logger at the time of the call
+	Message string  // formatted message, without header
+	Fields  Fields  // fields carried by the logger, must not be modified
+	Stack   []Frame // stack from the caller, for FATAL, PANIC and levels set by WithStacktrace
+
+	function string // caller function, when known at capture
+	color    string // ANSI SGR parameters of the writer being formatted for
+}
+
+var entryPool = sync.Pool{
+	New: func() interface{} {
+		return new(Entry)
+	},
+}
+
+func newEntry() *Entry {
+	return entryPool.Get().(*Entry)
+}
+
+func (e *Entry) release() {
+	*e = Entry{}
+	entryPool.Put(e)
+}
+
+// setCaller fills in the caller skip frames above setCaller, with the same
+// meaning of skip as runtime.Callers, passing over functions marked by
+// Helper.
+func (e *Entry) setCaller(skip int, helpers *helperSet) {
+	if helpers.empty() {
+		var pcs [1]uintptr
+		if runtime.Callers(skip+1, pcs[:]) == 0 {
+			e.File = "???"
+			e.Line = 0
+			return
+		}
+		e.setCallerPC(pcs[0])
+		return
+	}
+	var pcs [maxHelperDepth]uintptr
+	n := runtime.Callers(skip+1, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !more || !helpers.contains(frame.Function) {
+			if frame.PC == 0 {
+				e.File = "???"
+				e.Line = 0
+				return
+			}
+			// Inlined frames share their pc, so the function is kept
+			// rather than looked up again from e.PC.
+			e.PC = frame.PC + 1
+			e.File = frame.File
+			e.Line = frame.Line
+			e.function = frame.Function
+			return
+		}
+	}
+}
+
+// callerInfo is a caller resolved from its program counter.
+type callerInfo struct {
+	file     string
+	line     int
+	function string
+}
+
+// callers caches the resolved callers by program counter, since resolving
+// allocates and a program logs from a bounded set of call sites.
+var callers sync.Map // uintptr -> *callerInfo
+
+// setCallerPC fills in the caller from a program counter as returned by
+// runtime.Callers.
+func (e *Entry) setCallerPC(pc uintptr) {
+	e.PC = pc
+	v, ok := callers.Load(pc)
+	if !ok {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		v, _ = callers.LoadOrStore(pc, &callerInfo{file: frame.File, line: frame.Line, function: frame.Function})
+	}
+	c := v.(*callerInfo)
+	e.File = c.file
+	e.Line = c.line
+	e.function = c.function
+}
+
+// Function returns the package path-qualified name of the calling function,
+// e.g. "github.com/CodyGuo/glog.(*Logger).Info", or "" if it is unknown.
+func (e *Entry) Function() string {
+	if e.function != "" {
+		return e.function
+	}
+	if e.PC == 0 {
+		return ""
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{e.PC}).Next()
+	return frame.Function
+}
+
+// splitFunction splits a function name as returned by Function into the
+// package path and the name qualified by the package name, such as
+// "github.com/CodyGuo/glog" and "glog.(*Logger).Info".
+func splitFunction(function string) (pkg, name string) {
+	slash := strings.LastIndexByte(function, '/')
+	dot := strings.IndexByte(function[slash+1:], '.')
+	if dot < 0 {
+		return "", function
+	}
+	return function[:slash+1+dot], function[slash+1:]
+}
+
+// Color returns the ANSI SGR parameters, such as "32", that the record is
+// colored with by the text layout, or "" when color is off for the output
+// being written. See Lcolor.
+func (e *Entry) Color() string {
+	return e.color
+}
@@ -0,0 +1,60 @@
+package glog
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type entryRecorder struct {
+	entries []Entry
+}
+
+func (r *entryRecorder) Format(e *Entry, buf *[]byte) error {
+	r.entries = append(r.entries, *e)
+	return nil
+}
+
+func TestEntry(t *testing.T) {
+	var buf bytes.Buffer
+	rec := &entryRecorder{}
+	l := New(&buf, WithFlags(Lshortfile), WithPrefix("[p] "), WithFormatter(rec))
+	l.WithField("k", "v").Errorf("hello %s", "entry")
+	if len(rec.entries) != 1 {
+		t.Fatalf("entries: expected 1, got %d", len(rec.entries))
+	}
+	e := rec.entries[0]
+	if e.Logger == nil || e.Level != ERROR || e.Message != "hello entry" || e.Prefix != "[p] " {
+		t.Errorf("entry: unexpected %+v", e)
+	}
+	if e.Fields["k"] != "v" {
+		t.Errorf("entry fields: expected k=v, got %v", e.Fields)
+	}
+	if filepath.Base(e.File) != "entry_test.go" || e.Line == 0 || e.Time.IsZero() {
+		t.Errorf("entry caller: unexpected %s:%d at %v", e.File, e.Line, e.Time)
+	}
+	if fn := e.Function(); !strings.HasSuffix(fn, ".TestEntry") {
+		t.Errorf("entry function: expected *.TestEntry, got %q", fn)
+	}
+}
+
+func TestCallerCache(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lshortfile|Lfuncname))
+	for i := 0; i < 2; i++ {
+		l.Info("first")
+		l.Info("second")
+	}
+	line := "entry_test.go:46 glog.TestCallerCache: first\nentry_test.go:47 glog.TestCallerCache: second\n"
+	if got, want := buf.String(), line+line; got != want {
+		t.Errorf("caller cache: expected %q, got %q", want, got)
+	}
+}
+
+func TestCallerAllocs(t *testing.T) {
+	l := New(Discard, WithFlags(LstdFlags|Lshortfile|Lfuncname))
+	if n := testing.AllocsPerRun(100, func() { l.Info("hello") }); n != 0 {
+		t.Errorf("caller: expected no allocation per record, got %v", n)
+	}
+}
@@ -6,14 +6,14 @@ import (
 	"github.com/CodyGuo/glog"
 )
 
+var stdout = glog.New(os.Stdout, glog.WithFlags(glog.LglogFlags))
+
 func Info(v ...interface{}) {
-	glog.AutoCallDepth()
-	defer glog.ResetCallDepth()
 	glog.SetFlags(glog.LglogFlags)
-	glog.Info(v...)
+	glog.InfoDepth(1, v...)
 }
 
 func Infof(format string, v ...interface{}) {
-	l := glog.New(os.Stdout, glog.WithFlags(glog.LglogFlags), glog.WithAutoCallDepth())
-	l.Infof(format, v...)
+	stdout.Helper()
+	stdout.Infof(format, v...)
 }
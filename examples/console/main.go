@@ -17,7 +17,7 @@ func main() {
 	customLog := glog.New(os.Stdout,
 		glog.WithLevel(glog.TRACE),
 		glog.WithLevelLength(4),
-		glog.WithFlags(glog.LglogFlags),
+		glog.WithFlags(glog.LglogFlags|glog.Lcolor),
 		glog.WithPrefix("[customLog] "))
 
 	customLog.Trace("hello trace")
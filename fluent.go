@@ -0,0 +1,588 @@
+package glog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fluentTimeout bounds dialing, writing to and reading from a Fluentd
+// server.
+const fluentTimeout = 10 * time.Second
+
+// fluentProbeTimeout bounds the read of the heartbeat on an idle connection.
+const fluentProbeTimeout = time.Millisecond
+
+// FluentMode is the mode of the forward protocol used by a FluentWriter.
+type FluentMode int
+
+const (
+	FluentMessage       FluentMode = iota // one record per message, sent at once
+	FluentForward                         // batches of records as an array
+	FluentPackedForward                   // batches of records as a binary, optionally compressed
+)
+
+// FluentOption configures a FluentWriter.
+type FluentOption func(*fluentOptions)
+
+type fluentOptions struct {
+	tag       string
+	mode      FluentMode
+	batchSize int
+	interval  time.Duration
+	compress  bool
+	ack       bool
+	hostname  string
+	sharedKey string
+	username  string
+	password  string
+	heartbeat time.Duration
+	tls       *tls.Config
+}
+
+// FluentTag sets the tag of records. By default the tag is the prefix of a
+// record, trimmed of spaces, brackets and colons, or the base name of the
+// program when it has none.
+func FluentTag(tag string) FluentOption {
+	return func(o *fluentOptions) {
+		o.tag = tag
+	}
+}
+
+// FluentSendMode sets the mode of the forward protocol. Default
+// FluentMessage.
+func FluentSendMode(mode FluentMode) FluentOption {
+	return func(o *fluentOptions) {
+		o.mode = mode
+	}
+}
+
+// FluentBatch sends a batch of the FluentForward and FluentPackedForward
+// modes once it holds size records, and d after its first record. Default
+// 100 records and one second.
+func FluentBatch(size int, d time.Duration) FluentOption {
+	return func(o *fluentOptions) {
+		o.batchSize = size
+		o.interval = d
+	}
+}
+
+// FluentCompress compresses the batches of the FluentPackedForward mode
+// with gzip, the CompressedPackedForward mode of the protocol.
+func FluentCompress() FluentOption {
+	return func(o *fluentOptions) {
+		o.compress = true
+	}
+}
+
+// FluentAck asks the server to acknowledge every message, which is sent
+// again once after a missing or wrong acknowledgement.
+func FluentAck() FluentOption {
+	return func(o *fluentOptions) {
+		o.ack = true
+	}
+}
+
+// FluentSharedKey authenticates the connection with the handshake of the
+// forward protocol, as the shared_key of the security section of Fluentd
+// or Fluent Bit. The server sends HELO on connection, the writer answers
+// PING and the server confirms with PONG.
+func FluentSharedKey(key string) FluentOption {
+	return func(o *fluentOptions) {
+		o.sharedKey = key
+	}
+}
+
+// FluentUser sets the user authenticated by the handshake, for servers
+// with user_auth enabled.
+func FluentUser(username, password string) FluentOption {
+	return func(o *fluentOptions) {
+		o.username = username
+		o.password = password
+	}
+}
+
+// FluentHostname sets the hostname sent in the handshake. Default
+// os.Hostname.
+func FluentHostname(name string) FluentOption {
+	return func(o *fluentOptions) {
+		o.hostname = name
+	}
+}
+
+// FluentHeartbeat checks that the server has not closed a connection idle
+// for d before sending on it, as the heartbeat of the forward output of
+// Fluentd does, and dials again if it has. Otherwise the first message sent
+// on a connection closed by the server is lost. By default idle connections
+// are not checked.
+func FluentHeartbeat(d time.Duration) FluentOption {
+	return func(o *fluentOptions) {
+		o.heartbeat = d
+	}
+}
+
+// FluentTLS sets the configuration of the "tls" network.
+func FluentTLS(config *tls.Config) FluentOption {
+	return func(o *fluentOptions) {
+		o.tls = config
+	}
+}
+
+// FluentWriter is an EntryWriter sending records to Fluentd or Fluent Bit
+// with the forward protocol. Each record is sent with its time as an
+// EventTime and a map with the keys level, message, caller (file:line),
+// function, fields and stack, those without a value being left out. Plain
+// writes are sent at INFO.
+//
+// In the FluentMessage mode records are sent as they are written. In the
+// other modes they are batched and sent by FluentBatch, Flush and Close; a
+// record with a tag other than the one of the batch sends the batch first.
+// A failed connection is dialed again on the next write, and with
+// FluentHeartbeat one closed by the server is dialed again before it is
+// used.
+type FluentWriter struct {
+	mu        sync.Mutex
+	network   string
+	addr      string
+	opts      fluentOptions
+	closed    bool
+	conn      net.Conn
+	r         *bufio.Reader
+	keepalive bool      // false when the server closes connections after each message
+	used      time.Time // time of the last message on conn
+
+	tag   string // tag of the batch
+	batch []byte // [time, record] entries of the batch
+	count int
+	timer *time.Timer
+
+	buf  []byte // the message being sent
+	zbuf bytes.Buffer
+	zw   *gzip.Writer
+}
+
+// NewFluentWriter connects to the forward input at addr on network, one of
+// "tcp", "tls" and "unix". With an empty network and addr it connects to
+// 127.0.0.1:24224 over "tcp", the default of Fluentd and Fluent Bit.
+func NewFluentWriter(network, addr string, options ...FluentOption) (*FluentWriter, error) {
+	if network == "" && addr == "" {
+		network, addr = "tcp", "127.0.0.1:24224"
+	}
+	w := &FluentWriter{
+		network: network,
+		addr:    addr,
+		opts:    fluentOptions{batchSize: 100, interval: time.Second},
+	}
+	for _, option := range options {
+		option(&w.opts)
+	}
+	if w.opts.hostname == "" {
+		w.opts.hostname, _ = os.Hostname()
+	}
+	if w.opts.compress {
+		w.zw = gzip.NewWriter(nil)
+	}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// connect dials the server and performs the handshake when a shared key is
+// set. It must be called with w.mu held.
+func (w *FluentWriter) connect() error {
+	w.disconnect()
+	var (
+		conn net.Conn
+		err  error
+	)
+	if w.network == "tls" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: fluentTimeout}, "tcp", w.addr, w.opts.tls)
+	} else {
+		conn, err = net.DialTimeout(w.network, w.addr, fluentTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	w.conn, w.r, w.keepalive, w.used = conn, bufio.NewReader(conn), true, time.Now()
+	if w.opts.sharedKey != "" {
+		conn.SetDeadline(time.Now().Add(fluentTimeout))
+		if err := w.handshake(); err != nil {
+			w.disconnect()
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *FluentWriter) disconnect() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn, w.r = nil, nil
+	}
+}
+
+// handshake reads HELO, sends PING and checks PONG.
+func (w *FluentWriter) handshake() error {
+	helo, err := w.readCommand("HELO", 2)
+	if err != nil {
+		return err
+	}
+	options, _ := helo[1].(map[string]interface{})
+	nonce, auth := msgpackText(options["nonce"]), msgpackText(options["auth"])
+	if keepalive, ok := options["keepalive"].(bool); ok {
+		w.keepalive = keepalive
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	saltHex := hex.EncodeToString(salt)
+	var userDigest string
+	if w.opts.username != "" {
+		userDigest = sha512Hex(auth, w.opts.username, w.opts.password)
+	}
+	// PING is built apart from w.buf, which holds the message waiting
+	// for the connection.
+	ping := appendMsgpackArray(nil, 6)
+	ping = appendMsgpackString(ping, "PING")
+	ping = appendMsgpackString(ping, w.opts.hostname)
+	ping = appendMsgpackString(ping, saltHex)
+	ping = appendMsgpackString(ping, sha512Hex(saltHex, w.opts.hostname, nonce, w.opts.sharedKey))
+	ping = appendMsgpackString(ping, w.opts.username)
+	ping = appendMsgpackString(ping, userDigest)
+	if _, err := w.conn.Write(ping); err != nil {
+		return err
+	}
+
+	pong, err := w.readCommand("PONG", 5)
+	if err != nil {
+		return err
+	}
+	if ok, _ := pong[1].(bool); !ok {
+		return fmt.Errorf("glog: fluent handshake refused: %s", msgpackText(pong[2]))
+	}
+	if msgpackText(pong[4]) != sha512Hex(saltHex, msgpackText(pong[3]), nonce, w.opts.sharedKey) {
+		return errors.New("glog: fluent handshake: server digest mismatch")
+	}
+	return nil
+}
+
+// alive reports whether the server has not closed the connection. An idle
+// connection times out when read, while a closed one returns an error at
+// once.
+func (w *FluentWriter) alive() bool {
+	w.conn.SetReadDeadline(time.Now().Add(fluentProbeTimeout))
+	_, err := w.r.Peek(1)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return true
+	}
+	return err == nil
+}
+
+// readCommand reads an array of at least n values starting with name.
+func (w *FluentWriter) readCommand(name string, n int) ([]interface{}, error) {
+	v, err := readMsgpack(w.r)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := v.([]interface{})
+	if !ok || len(a) < n || msgpackText(a[0]) != name {
+		return nil, fmt.Errorf("glog: fluent handshake: expected %s, got %v", name, v)
+	}
+	return a, nil
+}
+
+func (w *FluentWriter) wantsCaller() bool {
+	return true
+}
+
+// WriteEntry sends e, or adds it to the batch being filled.
+func (w *FluentWriter) WriteEntry(e *Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	tag := w.opts.tag
+	if tag == "" {
+		tag = fluentTag(e.Prefix)
+	}
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	if w.opts.mode == FluentMessage {
+		w.buf = appendMsgpackArray(w.buf[:0], 3+w.optionCount(0))
+		w.buf = appendMsgpackString(w.buf, tag)
+		w.buf = appendEventTime(w.buf, t)
+		w.buf = appendFluentRecord(w.buf, e)
+		return w.send(0)
+	}
+
+	if w.count > 0 && tag != w.tag {
+		if err := w.flushLocked(); err != nil {
+			return err
+		}
+	}
+	w.tag = tag
+	w.batch = appendMsgpackArray(w.batch, 2)
+	w.batch = appendEventTime(w.batch, t)
+	w.batch = appendFluentRecord(w.batch, e)
+	w.count++
+	if w.count >= w.opts.batchSize {
+		return w.flushLocked()
+	}
+	if w.count == 1 {
+		w.timer = time.AfterFunc(w.opts.interval, w.flushTimer)
+	}
+	return nil
+}
+
+// Write sends p at INFO.
+func (w *FluentWriter) Write(p []byte) (int, error) {
+	if err := w.WriteEntry(&Entry{Time: time.Now(), Level: INFO, Message: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// appendFluentRecord writes the record map of e.
+func appendFluentRecord(b []byte, e *Entry) []byte {
+	function := e.Function()
+	n := 2
+	for _, ok := range []bool{e.File != "", function != "", len(e.Fields) > 0, len(e.Stack) > 0} {
+		if ok {
+			n++
+		}
+	}
+	b = appendMsgpackMap(b, n)
+	b = appendMsgpackString(b, "level")
+	b = appendMsgpackString(b, e.Level.String())
+	b = appendMsgpackString(b, "message")
+	b = appendMsgpackString(b, strings.TrimRight(e.Prefix+e.Message, "\n"))
+	if e.File != "" {
+		b = appendMsgpackString(b, "caller")
+		var caller []byte
+		appendFile(&caller, Llongfile, e.File, e.Line)
+		b = appendMsgpackString(b, string(caller))
+	}
+	if function != "" {
+		b = appendMsgpackString(b, "function")
+		b = appendMsgpackString(b, function)
+	}
+	if len(e.Fields) > 0 {
+		b = appendMsgpackString(b, "fields")
+		b = appendMsgpackFields(b, e.Fields)
+	}
+	if len(e.Stack) > 0 {
+		b = appendMsgpackString(b, "stack")
+		b = appendMsgpackArray(b, len(e.Stack))
+		for _, f := range e.Stack {
+			b = appendMsgpackMap(b, 3)
+			b = appendMsgpackString(b, "function")
+			b = appendMsgpackString(b, f.Function)
+			b = appendMsgpackString(b, "file")
+			b = appendMsgpackString(b, f.File)
+			b = appendMsgpackString(b, "line")
+			b = appendMsgpackInt(b, int64(f.Line))
+		}
+	}
+	return b
+}
+
+func (w *FluentWriter) flushTimer() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	if err := w.flushLocked(); err != nil {
+		fmt.Fprintf(os.Stderr, "glog: fluent flush failed, error: %v\n", err)
+	}
+}
+
+// Flush sends the batch being filled.
+func (w *FluentWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	return w.flushLocked()
+}
+
+// flushLocked sends the batch as a Forward or PackedForward message. The
+// batch is discarded even if it could not be sent.
+func (w *FluentWriter) flushLocked() error {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.count == 0 {
+		return nil
+	}
+	count := w.count
+	defer func() {
+		w.batch, w.count = w.batch[:0], 0
+	}()
+	w.buf = appendMsgpackArray(w.buf[:0], 2+w.optionCount(count))
+	w.buf = appendMsgpackString(w.buf, w.tag)
+	if w.opts.mode == FluentForward {
+		w.buf = appendMsgpackArray(w.buf, count)
+		w.buf = append(w.buf, w.batch...)
+	} else if w.zw != nil {
+		w.zbuf.Reset()
+		w.zw.Reset(&w.zbuf)
+		w.zw.Write(w.batch)
+		if err := w.zw.Close(); err != nil {
+			return err
+		}
+		w.buf = appendMsgpackBin(w.buf, w.zbuf.Bytes())
+	} else {
+		w.buf = appendMsgpackBin(w.buf, w.batch)
+	}
+	return w.send(count)
+}
+
+// optionCount returns 1 if a message of count records carries an option
+// map, 0 otherwise.
+func (w *FluentWriter) optionCount(count int) int {
+	if w.opts.ack || count > 0 {
+		return 1
+	}
+	return 0
+}
+
+// send appends the option map to the message in w.buf, when it has one, and
+// sends it, dialing again once after a failure.
+func (w *FluentWriter) send(count int) error {
+	var chunk string
+	if w.optionCount(count) > 0 {
+		n := 0
+		if w.opts.ack {
+			id := make([]byte, 16)
+			if _, err := rand.Read(id); err != nil {
+				return err
+			}
+			chunk = base64.StdEncoding.EncodeToString(id)
+			n++
+		}
+		if count > 0 {
+			n++
+		}
+		compressed := w.opts.mode == FluentPackedForward && w.zw != nil
+		if compressed {
+			n++
+		}
+		w.buf = appendMsgpackMap(w.buf, n)
+		if chunk != "" {
+			w.buf = appendMsgpackString(w.buf, "chunk")
+			w.buf = appendMsgpackString(w.buf, chunk)
+		}
+		if count > 0 {
+			w.buf = appendMsgpackString(w.buf, "size")
+			w.buf = appendMsgpackInt(w.buf, int64(count))
+		}
+		if compressed {
+			w.buf = appendMsgpackString(w.buf, "compressed")
+			w.buf = appendMsgpackString(w.buf, "gzip")
+		}
+	}
+	if w.conn != nil && w.opts.heartbeat > 0 && time.Since(w.used) >= w.opts.heartbeat && !w.alive() {
+		w.disconnect()
+	}
+	if w.conn != nil {
+		if err := w.write(chunk); err == nil {
+			return nil
+		}
+	}
+	if err := w.connect(); err != nil {
+		return err
+	}
+	return w.write(chunk)
+}
+
+// write sends the message in w.buf and, when chunk is set, waits for its
+// acknowledgement.
+func (w *FluentWriter) write(chunk string) error {
+	w.conn.SetDeadline(time.Now().Add(fluentTimeout))
+	_, err := w.conn.Write(w.buf)
+	if err == nil && chunk != "" {
+		var v interface{}
+		if v, err = readMsgpack(w.r); err == nil {
+			if m, _ := v.(map[string]interface{}); m == nil || msgpackText(m["ack"]) != chunk {
+				err = fmt.Errorf("glog: fluent: expected ack %s, got %v", chunk, v)
+			}
+		}
+	}
+	if err != nil || !w.keepalive {
+		w.disconnect()
+	}
+	w.used = time.Now()
+	return err
+}
+
+// Close sends the batch being filled and closes the connection. Later
+// writes fail with os.ErrClosed.
+func (w *FluentWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	err := w.flushLocked()
+	w.closed = true
+	w.disconnect()
+	return err
+}
+
+// fluentTag returns prefix trimmed of spaces, brackets and colons, with the
+// characters other than letters, digits, '.', '_' and '-' replaced by '_',
+// or the base name of the program for an empty prefix.
+func fluentTag(prefix string) string {
+	prefix = strings.Trim(prefix, " \t[]():")
+	if prefix == "" {
+		prefix = filepath.Base(os.Args[0])
+	}
+	b := []byte(prefix)
+	for i, c := range b {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '_' || c == '-') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// msgpackText returns v as a string when it is a string or a binary.
+func msgpackText(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return ""
+}
+
+// sha512Hex returns the hexadecimal SHA-512 digest of the concatenated parts.
+func sha512Hex(parts ...string) string {
+	h := sha512.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
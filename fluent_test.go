@@ -0,0 +1,345 @@
+package glog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fluentServer accepts one connection at a time from a FluentWriter.
+type fluentServer struct {
+	ln    net.Listener
+	conns chan net.Conn
+}
+
+func newFluentServer(t *testing.T) *fluentServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fluentServer{ln: ln, conns: make(chan net.Conn, 4)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			s.conns <- conn
+		}
+	}()
+	return s
+}
+
+func (s *fluentServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fluentServer) accept(t *testing.T) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	select {
+	case conn := <-s.conns:
+		return conn, bufio.NewReader(conn)
+	case <-time.After(5 * time.Second):
+		t.Fatal("fluent: no connection")
+	}
+	return nil, nil
+}
+
+// readFluent reads a message as an array.
+func readFluent(t *testing.T, r *bufio.Reader) []interface{} {
+	t.Helper()
+	v, err := readMsgpack(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, ok := v.([]interface{})
+	if !ok {
+		t.Fatalf("fluent: expected an array, got %v", v)
+	}
+	return a
+}
+
+// eventTime decodes an EventTime.
+func eventTime(t *testing.T, v interface{}) time.Time {
+	t.Helper()
+	ext, ok := v.(msgpackExt)
+	if !ok || ext.Type != 0 || len(ext.Data) != 8 {
+		t.Fatalf("fluent: expected an EventTime, got %v", v)
+	}
+	return time.Unix(int64(readBigEndian(ext.Data[:4])), int64(readBigEndian(ext.Data[4:])))
+}
+
+func TestFluentMessage(t *testing.T) {
+	s := newFluentServer(t)
+	w, err := NewFluentWriter("tcp", s.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, r := s.accept(t)
+	l := New(Discard, WithFlags(0), WithPrefix("[app] "), WithMultiWriteCloser(w))
+	defer l.Close()
+	start := time.Now()
+	l.WithFields(Fields{"user": "u", "n": 7}).Warning("hello\n")
+
+	msg := readFluent(t, r)
+	if len(msg) != 3 || msg[0] != "app" {
+		t.Fatalf("fluent message: unexpected %v", msg)
+	}
+	if tm := eventTime(t, msg[1]); tm.Before(start.Truncate(time.Second)) || time.Since(tm) > time.Minute {
+		t.Errorf("fluent time: unexpected %v", tm)
+	}
+	record := msg[2].(map[string]interface{})
+	caller, _ := record["caller"].(string)
+	if !strings.HasSuffix(caller, "/fluent_test.go:92") {
+		t.Errorf("fluent caller: unexpected %q", caller)
+	}
+	delete(record, "caller")
+	want := map[string]interface{}{
+		"level":    "WARNING",
+		"message":  "[app] hello",
+		"function": "github.com/CodyGuo/glog.TestFluentMessage",
+		"fields":   map[string]interface{}{"user": "u", "n": int64(7)},
+	}
+	if !reflect.DeepEqual(record, want) {
+		t.Errorf("fluent record: expected %v, got %v", want, record)
+	}
+
+	w.Write([]byte("plain"))
+	if msg := readFluent(t, r); msg[0] != fluentTag("") || msg[2].(map[string]interface{})["level"] != "INFO" {
+		t.Errorf("fluent write: unexpected %v", msg)
+	}
+}
+
+func TestFluentForward(t *testing.T) {
+	s := newFluentServer(t)
+	w, err := NewFluentWriter("tcp", s.addr(), FluentTag("app.web"), FluentSendMode(FluentForward),
+		FluentBatch(2, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	_, r := s.accept(t)
+	for _, m := range []string{"one", "two", "three"} {
+		w.Write([]byte(m))
+	}
+	w.Flush()
+	for _, want := range [][]string{{"one", "two"}, {"three"}} {
+		msg := readFluent(t, r)
+		if len(msg) != 3 || msg[0] != "app.web" {
+			t.Fatalf("fluent forward: unexpected %v", msg)
+		}
+		entries := msg[1].([]interface{})
+		var got []string
+		for _, e := range entries {
+			pair := e.([]interface{})
+			eventTime(t, pair[0])
+			got = append(got, pair[1].(map[string]interface{})["message"].(string))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("fluent forward: expected %q, got %q", want, got)
+		}
+		if option := msg[2].(map[string]interface{}); option["size"] != int64(len(want)) {
+			t.Errorf("fluent forward: unexpected option %v", option)
+		}
+	}
+}
+
+func TestFluentPackedForward(t *testing.T) {
+	s := newFluentServer(t)
+	w, err := NewFluentWriter("tcp", s.addr(), FluentSendMode(FluentPackedForward), FluentCompress(),
+		FluentAck(), FluentBatch(10, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	conn, r := s.accept(t)
+	// A record with another tag sends the batch; the second batch is sent
+	// by the timer.
+	w.WriteEntry(&Entry{Prefix: "[a] ", Message: "1"})
+	w.WriteEntry(&Entry{Prefix: "[a] ", Message: "2"})
+	done := make(chan error, 1)
+	go func() {
+		done <- w.WriteEntry(&Entry{Prefix: "[b] ", Message: "3"})
+	}()
+	for _, want := range []struct {
+		tag      string
+		messages []string
+	}{{"a", []string{"[a] 1", "[a] 2"}}, {"b", []string{"[b] 3"}}} {
+		msg := readFluent(t, r)
+		option := msg[2].(map[string]interface{})
+		if msg[0] != want.tag || option["size"] != int64(len(want.messages)) || option["compressed"] != "gzip" {
+			t.Fatalf("fluent packed: unexpected %v", msg)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(msg[1].([]byte)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		packed, err := ioutil.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pr := bufio.NewReader(bytes.NewReader(packed))
+		for _, m := range want.messages {
+			pair := readFluent(t, pr)
+			if got := pair[1].(map[string]interface{})["message"]; got != m {
+				t.Errorf("fluent packed: expected %q, got %v", m, got)
+			}
+		}
+		if _, err := pr.ReadByte(); err == nil {
+			t.Errorf("fluent packed: more than %d entries", len(want.messages))
+		}
+		conn.Write(appendMsgpackString(appendMsgpackString(appendMsgpackMap(nil, 1), "ack"), option["chunk"].(string)))
+	}
+	if err := <-done; err != nil {
+		t.Errorf("fluent ack: %v", err)
+	}
+}
+
+func TestFluentAckMismatch(t *testing.T) {
+	s := newFluentServer(t)
+	w, err := NewFluentWriter("tcp", s.addr(), FluentAck())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- w.WriteEntry(&Entry{Message: "m"})
+	}()
+	// The message is sent again on a new connection after a wrong ack.
+	for i := 0; i < 2; i++ {
+		conn, r := s.accept(t)
+		msg := readFluent(t, r)
+		chunk := msg[3].(map[string]interface{})["chunk"].(string)
+		if i == 0 {
+			chunk = "wrong"
+		}
+		conn.Write(appendMsgpackString(appendMsgpackString(appendMsgpackMap(nil, 1), "ack"), chunk))
+	}
+	if err := <-done; err != nil {
+		t.Errorf("fluent ack retry: %v", err)
+	}
+}
+
+func TestFluentHeartbeat(t *testing.T) {
+	s := newFluentServer(t)
+	w, err := NewFluentWriter("tcp", s.addr(), FluentHeartbeat(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	first, r := s.accept(t)
+	w.Write([]byte("one"))
+	readFluent(t, r)
+	// The message following the close is sent on a new connection instead
+	// of being written to the closed one.
+	first.Close()
+	time.Sleep(10 * time.Millisecond)
+	if _, err := w.Write([]byte("two")); err != nil {
+		t.Fatal(err)
+	}
+	_, r = s.accept(t)
+	if msg := readFluent(t, r); msg[2].(map[string]interface{})["message"] != "two" {
+		t.Errorf("fluent heartbeat: unexpected %v", msg)
+	}
+}
+
+// serveHandshake sends HELO, checks PING and answers PONG as a server with
+// key and the user u with password p.
+func serveHandshake(t *testing.T, conn net.Conn, r *bufio.Reader, key, u, p string, keepalive bool) {
+	helo := appendMsgpackArray(nil, 2)
+	helo = appendMsgpackString(helo, "HELO")
+	helo = appendMsgpackMap(helo, 3)
+	helo = appendMsgpackString(helo, "nonce")
+	helo = appendMsgpackBin(helo, []byte("nonce"))
+	helo = appendMsgpackString(helo, "auth")
+	helo = appendMsgpackBin(helo, []byte("salt"))
+	helo = appendMsgpackString(helo, "keepalive")
+	helo = appendMsgpackBool(helo, keepalive)
+	conn.Write(helo)
+
+	ping := readFluent(t, r)
+	if len(ping) != 6 || ping[0] != "PING" || ping[1] != "client" {
+		t.Fatalf("fluent handshake: unexpected %v", ping)
+	}
+	salt := ping[2].(string)
+	ok := ping[3] == sha512Hex(salt, "client", "nonce", key) && ping[4] == u && ping[5] == sha512Hex("salt", u, p)
+	pong := appendMsgpackArray(nil, 5)
+	pong = appendMsgpackString(pong, "PONG")
+	pong = appendMsgpackBool(pong, ok)
+	pong = appendMsgpackString(pong, "bad credentials")
+	pong = appendMsgpackString(pong, "server")
+	pong = appendMsgpackString(pong, sha512Hex(salt, "server", "nonce", key))
+	conn.Write(pong)
+}
+
+func TestFluentHandshake(t *testing.T) {
+	s := newFluentServer(t)
+	refused := make(chan error, 1)
+	go func() {
+		_, err := NewFluentWriter("tcp", s.addr(), FluentSharedKey("wrong"), FluentHostname("client"),
+			FluentUser("user", "secret"))
+		refused <- err
+	}()
+	conn, r := s.accept(t)
+	serveHandshake(t, conn, r, "key", "user", "secret", true)
+	if err := <-refused; err == nil || !strings.Contains(err.Error(), "bad credentials") {
+		t.Errorf("fluent handshake: expected a refusal, got %v", err)
+	}
+
+	// Without keepalive, every message is sent on a new connection.
+	type result struct {
+		w   *FluentWriter
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		w, err := NewFluentWriter("tcp", s.addr(), FluentSharedKey("key"), FluentHostname("client"),
+			FluentUser("user", "secret"))
+		for _, m := range []string{"one", "two"} {
+			if err == nil {
+				_, err = w.Write([]byte(m))
+			}
+		}
+		done <- result{w, err}
+	}()
+	for _, want := range []string{"one", "two"} {
+		conn, r := s.accept(t)
+		serveHandshake(t, conn, r, "key", "user", "secret", false)
+		msg := readFluent(t, r)
+		if got := msg[2].(map[string]interface{})["message"]; got != want {
+			t.Errorf("fluent handshake: expected %q, got %v", want, got)
+		}
+	}
+	res := <-done
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	res.w.Close()
+	if _, err := res.w.Write([]byte("closed")); err != os.ErrClosed {
+		t.Errorf("fluent closed: expected os.ErrClosed, got %v", err)
+	}
+}
+
+func TestFluentTag(t *testing.T) {
+	for prefix, want := range map[string]string{
+		"[app] ":      "app",
+		"app.web: ":   "app.web",
+		"(my app)":    "my_app",
+		"svc/worker ": "svc_worker",
+	} {
+		if got := fluentTag(prefix); got != want {
+			t.Errorf("fluentTag(%q): expected %q, got %q", prefix, want, got)
+		}
+	}
+}
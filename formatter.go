@@ -0,0 +1,336 @@
+package glog
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A Formatter lays out an Entry, appending the result to buf.
+// The logger adds a trailing newline when the result lacks one.
+//
+// Format is called with the logger's lock held, so it must not call
+// methods of e.Logger.
+type Formatter interface {
+	Format(e *Entry, buf *[]byte) error
+}
+
+// TextFormatter is the plain text layout selected by default. Its header is
+// controlled by the Ldate, Ltime, Lmicroseconds, Llongfile, Lshortfile,
+// Lfuncname, Lpackage, LUTC, Lmsgprefix, Lmsglevel, Lcolor and Lcolorline
+// flags of the logger.
+type TextFormatter struct{}
+
+// JSONFormatter writes one JSON object per record. It is selected by the
+// Lmsgjson flag; the other flags choose which keys are present.
+type JSONFormatter struct{}
+
+// formatterLocked returns the Formatter set with WithFormatter or SetFormatter,
+// falling back to the built-in layout chosen by the Lmsgjson flag.
+func (l *Logger) formatterLocked() Formatter {
+	if l.formatter != nil {
+		return l.formatter
+	}
+	if l.flag&Lmsgjson != 0 {
+		return JSONFormatter{}
+	}
+	return TextFormatter{}
+}
+
+// Format writes the header to buf in following order, followed by the
+// message and the fields. An Entry with a zero Time has no date or time.
+//   - e.Prefix (if it's not blank and Lmsgprefix is unset),
+//   - date and/or time (if corresponding flags are provided or WithTimeFormat is set),
+//   - file and line number, and function (if corresponding flags are provided),
+//   - e.Prefix (if it's not blank and Lmsgprefix is set),
+//   - level (if Lmsglevel is set).
+//
+// On terminals the level is colored when Lcolor is set and the whole line
+// when Lcolorline is set. A stack trace follows on indented lines.
+func (TextFormatter) Format(e *Entry, buf *[]byte) error {
+	l := e.Logger
+	colorLine := e.color != "" && l.flag&Lcolorline != 0
+	if colorLine {
+		appendColor(buf, e.color)
+	}
+	if l.flag&Lmsgprefix == 0 {
+		*buf = append(*buf, e.Prefix...)
+	}
+	if l.hasTime() && !e.Time.IsZero() {
+		appendTime(buf, l, e.Time)
+		*buf = append(*buf, ' ')
+	}
+	if l.flag&callerFlags != 0 {
+		appendCaller(buf, l.flag, e)
+		*buf = append(*buf, ": "...)
+	}
+	if l.flag&Lmsgprefix != 0 {
+		*buf = append(*buf, e.Prefix...)
+	}
+	if l.flag&Lmsglevel != 0 {
+		colorLevel := e.color != "" && !colorLine
+		if colorLevel {
+			appendColor(buf, e.color)
+		}
+		*buf = append(*buf, '[')
+		appendLevel(buf, e.Level, l.levelLength)
+		*buf = append(*buf, ']')
+		if colorLevel {
+			appendReset(buf)
+		}
+		*buf = append(*buf, ' ')
+	}
+	*buf = append(*buf, e.Message...)
+	if len(e.Fields) > 0 || colorLine || len(e.Stack) > 0 {
+		if n := len(*buf); n > 0 && (*buf)[n-1] == '\n' {
+			*buf = (*buf)[:n-1]
+		}
+		appendFields(buf, e.Fields)
+	}
+	if colorLine {
+		appendReset(buf)
+	}
+	appendStack(buf, e.Stack)
+	return nil
+}
+
+// Format writes e as a JSON object with the keys time, level, file, message
+// and fields. Keys whose flag is unset, and empty fields, are omitted. The
+// time is a number for the Unix layouts of WithTimeFormat, a string otherwise.
+// The file is a "file.go:23" string, or an object with the keys name, line,
+// function and package when Lfuncname or Lpackage is set. A stack trace is
+// an array of frame objects under the key stack.
+func (JSONFormatter) Format(e *Entry, buf *[]byte) error {
+	l := e.Logger
+	var jsonData = struct {
+		Time    json.RawMessage `json:"time,omitempty"`
+		Level   string          `json:"level,omitempty"`
+		File    interface{}     `json:"file,omitempty"`
+		Message string          `json:"message"`
+		Fields  Fields          `json:"fields,omitempty"`
+		Stack   []Frame         `json:"stack,omitempty"`
+	}{Fields: e.Fields, Stack: e.Stack}
+	if l.hasTime() && !e.Time.IsZero() {
+		appendTime(buf, l, e.Time)
+		if epochFormat(l.timeFormat) {
+			jsonData.Time = append(json.RawMessage(nil), *buf...)
+		} else {
+			jsonData.Time = strconv.AppendQuote(nil, string(*buf))
+		}
+		*buf = (*buf)[:0]
+	}
+	if l.flag&(Lfuncname|Lpackage) != 0 {
+		jsonData.File = newJSONCaller(l.flag, e)
+	} else if l.flag&(Lshortfile|Llongfile) != 0 {
+		appendFile(buf, l.flag, e.File, e.Line)
+		jsonData.File = string(*buf)
+		*buf = (*buf)[:0]
+	}
+	if l.flag&Lmsglevel != 0 {
+		appendLevel(buf, e.Level, l.levelLength)
+		jsonData.Level = string(*buf)
+		*buf = (*buf)[:0]
+	}
+	*buf = append(*buf, e.Prefix...)
+	*buf = append(*buf, e.Message...)
+	jsonData.Message = string(*buf)
+	*buf = (*buf)[:0]
+
+	jsonBytes, err := json.Marshal(&jsonData)
+	if err != nil {
+		return fmt.Errorf("json format failed, error: %v", err)
+	}
+	*buf = append(*buf, jsonBytes...)
+	return nil
+}
+
+// hasTime reports whether records carry a time, set with WithTimeFormat or
+// by the Ldate, Ltime and Lmicroseconds flags.
+func (l *Logger) hasTime() bool {
+	return l.timeFormat != "" || l.flag&(Ldate|Ltime|Lmicroseconds) != 0
+}
+
+// recordTime returns t in the location records are shown in: UTC with
+// LUTC, else the location set with WithLocation.
+func (l *Logger) recordTime(t time.Time) time.Time {
+	if l.flag&LUTC != 0 {
+		return t.UTC()
+	}
+	if l.location != nil {
+		return t.In(l.location)
+	}
+	return t
+}
+
+// appendTime writes t in the layout set with WithTimeFormat, in the location
+// set with WithLocation, or in the layout chosen by Ldate, Ltime and
+// Lmicroseconds. LUTC takes precedence over the location.
+func appendTime(buf *[]byte, l *Logger, t time.Time) {
+	t = l.recordTime(t)
+	switch l.timeFormat {
+	case "":
+	case TimeUnix:
+		*buf = strconv.AppendInt(*buf, t.Unix(), 10)
+		return
+	case TimeUnixMilli:
+		*buf = strconv.AppendInt(*buf, t.UnixMilli(), 10)
+		return
+	case TimeUnixMicro:
+		*buf = strconv.AppendInt(*buf, t.UnixMicro(), 10)
+		return
+	case TimeUnixNano:
+		*buf = strconv.AppendInt(*buf, t.UnixNano(), 10)
+		return
+	default:
+		*buf = t.AppendFormat(*buf, l.timeFormat)
+		return
+	}
+	if l.flag&Ldate != 0 {
+		year, month, day := t.Date()
+		itoa(buf, year, 4)
+		*buf = append(*buf, '/')
+		itoa(buf, int(month), 2)
+		*buf = append(*buf, '/')
+		itoa(buf, day, 2)
+		if l.flag&(Ltime|Lmicroseconds) != 0 {
+			*buf = append(*buf, ' ')
+		}
+	}
+	if l.flag&(Ltime|Lmicroseconds) != 0 {
+		hour, min, sec := t.Clock()
+		itoa(buf, hour, 2)
+		*buf = append(*buf, ':')
+		itoa(buf, min, 2)
+		*buf = append(*buf, ':')
+		itoa(buf, sec, 2)
+		if l.flag&Lmicroseconds != 0 {
+			*buf = append(*buf, '.')
+			itoa(buf, t.Nanosecond()/1e3, 6)
+		}
+	}
+}
+
+// appendFile writes file:line, trimming file to its final element when
+// Lshortfile is set in flag.
+func appendFile(buf *[]byte, flag int, file string, line int) {
+	*buf = append(*buf, trimFile(flag, file)...)
+	*buf = append(*buf, ':')
+	itoa(buf, line, -1)
+}
+
+// trimFile returns the final element of file when Lshortfile is set in flag.
+func trimFile(flag int, file string) string {
+	if flag&Lshortfile != 0 {
+		for i := len(file) - 1; i > 0; i-- {
+			if file[i] == '/' {
+				return file[i+1:]
+			}
+		}
+	}
+	return file
+}
+
+// callerFunction returns the function of e as selected by Lfuncname and
+// Lpackage: the name qualified by the package name, the package path, or
+// both together.
+func callerFunction(flag int, e *Entry) string {
+	function := e.Function()
+	if function == "" {
+		return "???"
+	}
+	switch pkg, name := splitFunction(function); flag & (Lfuncname | Lpackage) {
+	case Lfuncname:
+		return name
+	case Lpackage:
+		return pkg
+	}
+	return function
+}
+
+// appendCaller writes file:line and the function of e, each if selected by
+// flag, separated by a space.
+func appendCaller(buf *[]byte, flag int, e *Entry) {
+	if flag&(Lshortfile|Llongfile) != 0 {
+		appendFile(buf, flag, e.File, e.Line)
+		if flag&(Lfuncname|Lpackage) != 0 {
+			*buf = append(*buf, ' ')
+		}
+	}
+	if flag&(Lfuncname|Lpackage) != 0 {
+		*buf = append(*buf, callerFunction(flag, e)...)
+	}
+}
+
+// jsonCaller is the file object of JSONFormatter.
+type jsonCaller struct {
+	Name     string `json:"name,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Function string `json:"function,omitempty"`
+	Package  string `json:"package,omitempty"`
+}
+
+func newJSONCaller(flag int, e *Entry) *jsonCaller {
+	c := &jsonCaller{}
+	if flag&(Lshortfile|Llongfile) != 0 {
+		c.Name = trimFile(flag, e.File)
+		c.Line = e.Line
+	}
+	pkg, name := splitFunction(e.Function())
+	if flag&Lfuncname != 0 {
+		c.Function = name
+	}
+	if flag&Lpackage != 0 {
+		c.Package = pkg
+	}
+	return c
+}
+
+// appendLevel writes the name of level, truncated to length when it is set.
+// A registered level with a short name uses it rather than a truncated name.
+func appendLevel(buf *[]byte, level Level, length uint8) {
+	s := level.String()
+	if 0 < length && int(length) < len(s) {
+		if info, ok := level.info(); ok && info.short != "" {
+			s = info.short
+		}
+		if int(length) < len(s) {
+			s = s[:length]
+		}
+	}
+	*buf = append(*buf, s...)
+}
+
+// appendFields writes fields to buf as " key=value" pairs sorted by key.
+// Values containing spaces, quotes or '=' are quoted.
+func appendFields(buf *[]byte, fields Fields) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		*buf = append(*buf, ' ')
+		*buf = append(*buf, k...)
+		*buf = append(*buf, '=')
+		v := fmt.Sprint(fields[k])
+		if needsQuoting(v) {
+			*buf = strconv.AppendQuote(*buf, v)
+		} else {
+			*buf = append(*buf, v...)
+		}
+	}
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c == '"' || c == '=' || c >= 0x7f {
+			return true
+		}
+	}
+	return false
+}
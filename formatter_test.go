@@ -0,0 +1,81 @@
+package glog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type upperFormatter struct{}
+
+func (upperFormatter) Format(e *Entry, buf *[]byte) error {
+	*buf = append(*buf, e.Level.String()...)
+	*buf = append(*buf, ' ')
+	*buf = append(*buf, strings.ToUpper(e.Message)...)
+	return nil
+}
+
+func TestFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lmsgjson|Lmsglevel), WithFormatter(upperFormatter{}))
+	l.Warning("hello")
+	if got, want := buf.String(), "WARNING HELLO\n"; got != want {
+		t.Errorf("custom formatter: expected %q, got %q", want, got)
+	}
+
+	buf.Reset()
+	l.SetFormatter(nil)
+	l.Warning("hello")
+	if got, want := buf.String(), `{"level":"WARNING","message":"hello"}`+"\n"; got != want {
+		t.Errorf("Lmsgjson shim: expected %q, got %q", want, got)
+	}
+
+	buf.Reset()
+	l.SetFormatter(TextFormatter{})
+	l.Warning("hello")
+	if got, want := buf.String(), "[WARNING] hello\n"; got != want {
+		t.Errorf("text formatter: expected %q, got %q", want, got)
+	}
+}
+
+type service struct{ l *Logger }
+
+func (s *service) serve() {
+	s.l.Info("serving")
+}
+
+func TestFuncname(t *testing.T) {
+	var buf bytes.Buffer
+	s := &service{New(&buf, WithFlags(Lshortfile|Lfuncname))}
+	s.serve()
+	s.l.SetFlags(Lfuncname | Lpackage)
+	s.serve()
+	s.l.SetFlags(Lpackage)
+	s.serve()
+	want := "formatter_test.go:44 glog.(*service).serve: serving\n" +
+		"github.com/CodyGuo/glog.(*service).serve: serving\n" +
+		"github.com/CodyGuo/glog: serving\n"
+	if got := buf.String(); got != want {
+		t.Errorf("funcname: expected %q, got %q", want, got)
+	}
+
+	buf.Reset()
+	s.l.SetFlags(Lmsgjson | Lshortfile | Lfuncname | Lpackage)
+	s.serve()
+	want = `{"file":{"name":"formatter_test.go","line":44,"function":"glog.(*service).serve","package":"github.com/CodyGuo/glog"},"message":"serving"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("funcname json: expected %q, got %q", want, got)
+	}
+}
+
+func TestSplitFunction(t *testing.T) {
+	for function, want := range map[string][2]string{
+		"github.com/CodyGuo/glog.(*Logger).Info": {"github.com/CodyGuo/glog", "glog.(*Logger).Info"},
+		"main.main.func1":                        {"main", "main.main.func1"},
+		"example.com/a.b/c.F":                    {"example.com/a.b/c", "c.F"},
+	} {
+		if pkg, name := splitFunction(function); pkg != want[0] || name != want[1] {
+			t.Errorf("splitFunction(%q): got %q, %q", function, pkg, name)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package glog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// gelfTimeout bounds dialing and writing to a GELF server.
+	gelfTimeout = 10 * time.Second
+
+	// gelfChunkHeader is the size of the header of a chunk: the magic
+	// bytes, the message id, the sequence number and the sequence count.
+	gelfChunkHeader = 12
+
+	// gelfMaxChunks is the most chunks a message may be split into.
+	gelfMaxChunks = 128
+)
+
+// GelfCompression is the compression of the datagrams of a GelfWriter.
+type GelfCompression int
+
+const (
+	GelfGzip GelfCompression = iota
+	GelfZlib
+	GelfUncompressed
+)
+
+// GelfOption configures a GelfWriter.
+type GelfOption func(*gelfOptions)
+
+type gelfOptions struct {
+	host        string
+	compression GelfCompression
+	chunkSize   int
+	tls         *tls.Config
+}
+
+// GelfHost sets the host of messages. Default os.Hostname.
+func GelfHost(name string) GelfOption {
+	return func(o *gelfOptions) {
+		o.host = name
+	}
+}
+
+// GelfCompress sets the compression of datagrams on "udp". Default GelfGzip.
+// Messages sent over "tcp" and "tls" are never compressed.
+func GelfCompress(c GelfCompression) GelfOption {
+	return func(o *gelfOptions) {
+		o.compression = c
+	}
+}
+
+// GelfChunkSize sets the largest datagram sent on "udp"; larger messages are
+// split into chunks. Default 1420, which fits most networks; 8154 suits a
+// local network.
+func GelfChunkSize(size int) GelfOption {
+	return func(o *gelfOptions) {
+		o.chunkSize = size
+	}
+}
+
+// GelfTLS sets the configuration of the "tls" network.
+func GelfTLS(config *tls.Config) GelfOption {
+	return func(o *gelfOptions) {
+		o.tls = config
+	}
+}
+
+// GelfWriter is an EntryWriter sending records to Graylog as GELF 1.1
+// messages with these keys:
+//
+//   - short_message, the first line of the prefix and message,
+//   - full_message, the whole message followed by the stack trace, when
+//     either spans several lines,
+//   - level, the syslog severity of the level, see SyslogWriter,
+//   - _glog_level, the name of the level,
+//   - _file, _line and _function, the caller,
+//   - every field, its key prefixed by '_' with the characters other than
+//     letters, digits, '_', '.' and '-' replaced by '_'. A field "id",
+//     reserved by Graylog, is sent as "__id", and a field that would replace
+//     one of the keys above, such as "file", as "_field_file".
+//
+// Messages are compressed and split into chunks of at most GelfChunkSize
+// bytes on "udp", and terminated by a null byte on "tcp" and "tls". A failed
+// connection is dialed again on the next write.
+type GelfWriter struct {
+	mu      sync.Mutex
+	network string
+	addr    string
+	opts    gelfOptions
+	closed  bool
+	conn    net.Conn
+	zw      gelfCompressor
+	zbuf    bytes.Buffer
+	frame   []byte // a chunk or a framed message
+}
+
+// gelfCompressor is implemented by *gzip.Writer and *zlib.Writer.
+type gelfCompressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// NewGelfWriter connects to the GELF input at addr on network, one of
+// "udp", "tcp" and "tls".
+func NewGelfWriter(network, addr string, options ...GelfOption) (*GelfWriter, error) {
+	w := &GelfWriter{
+		network: network,
+		addr:    addr,
+		opts:    gelfOptions{chunkSize: 1420},
+	}
+	for _, option := range options {
+		option(&w.opts)
+	}
+	if w.opts.host == "" {
+		w.opts.host, _ = os.Hostname()
+	}
+	if w.opts.chunkSize <= gelfChunkHeader {
+		return nil, fmt.Errorf("glog: GELF chunk size %d too small", w.opts.chunkSize)
+	}
+	if w.udp() {
+		switch w.opts.compression {
+		case GelfGzip:
+			w.zw = gzip.NewWriter(nil)
+		case GelfZlib:
+			w.zw = zlib.NewWriter(nil)
+		}
+	}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *GelfWriter) udp() bool {
+	return strings.HasPrefix(w.network, "udp")
+}
+
+// connect dials the server. It must be called with w.mu held.
+func (w *GelfWriter) connect() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if w.network == "tls" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: gelfTimeout}, "tcp", w.addr, w.opts.tls)
+	} else {
+		conn, err = net.DialTimeout(w.network, w.addr, gelfTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+func (w *GelfWriter) wantsCaller() bool {
+	return true
+}
+
+// WriteEntry sends e at the syslog severity of its level.
+func (w *GelfWriter) WriteEntry(e *Entry) error {
+	severity := 6
+	if info, ok := e.Level.info(); ok {
+		severity = info.syslog
+	}
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	m := make(map[string]interface{}, len(e.Fields)+10)
+	for k, v := range e.Fields {
+		m[gelfFieldName(k)] = gelfValue(v)
+	}
+	w.header(m, severity, t, e.Prefix+e.Message, e.Stack)
+	m["_glog_level"] = e.Level.String()
+	if e.File != "" {
+		m["_file"] = e.File
+		m["_line"] = e.Line
+	}
+	if function := e.Function(); function != "" {
+		m["_function"] = function
+	}
+	return w.send(m)
+}
+
+// Write sends p at info.
+func (w *GelfWriter) Write(p []byte) (int, error) {
+	m := make(map[string]interface{}, 6)
+	w.header(m, 6, time.Now(), string(p), nil)
+	if err := w.send(m); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// header sets the keys of m common to every message.
+func (w *GelfWriter) header(m map[string]interface{}, severity int, t time.Time, msg string, stack []Frame) {
+	msg = strings.TrimRight(msg, "\n")
+	short := msg
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		short = msg[:i]
+	}
+	if short != msg || len(stack) > 0 {
+		full := []byte(msg)
+		appendStack(&full, stack)
+		m["full_message"] = string(full)
+	}
+	m["version"] = "1.1"
+	m["host"] = w.opts.host
+	m["short_message"] = nilValue(short)
+	m["timestamp"] = json.Number(fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/1e3))
+	m["level"] = severity
+}
+
+func (w *GelfWriter) send(m map[string]interface{}) error {
+	p, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.udp() {
+		if p, err = w.compress(p); err != nil {
+			return err
+		}
+		if n := w.chunks(p); n > gelfMaxChunks {
+			return fmt.Errorf("glog: GELF message of %d bytes exceeds %d chunks", len(p), gelfMaxChunks)
+		}
+	}
+	if w.conn != nil {
+		if err := w.write(p); err == nil {
+			return nil
+		}
+	}
+	if err := w.connect(); err != nil {
+		return err
+	}
+	return w.write(p)
+}
+
+// compress returns p compressed as set with GelfCompress.
+func (w *GelfWriter) compress(p []byte) ([]byte, error) {
+	if w.zw == nil {
+		return p, nil
+	}
+	w.zbuf.Reset()
+	w.zw.Reset(&w.zbuf)
+	if _, err := w.zw.Write(p); err != nil {
+		return nil, err
+	}
+	if err := w.zw.Close(); err != nil {
+		return nil, err
+	}
+	return w.zbuf.Bytes(), nil
+}
+
+// chunks returns the number of datagrams p is sent in.
+func (w *GelfWriter) chunks(p []byte) int {
+	if len(p) <= w.opts.chunkSize {
+		return 1
+	}
+	size := w.opts.chunkSize - gelfChunkHeader
+	return (len(p) + size - 1) / size
+}
+
+// write sends p, in chunks on "udp" and terminated by a null byte on "tcp"
+// and "tls".
+func (w *GelfWriter) write(p []byte) error {
+	w.conn.SetWriteDeadline(time.Now().Add(gelfTimeout))
+	if !w.udp() {
+		w.frame = append(append(w.frame[:0], p...), 0)
+		_, err := w.conn.Write(w.frame)
+		return err
+	}
+	n := w.chunks(p)
+	if n == 1 {
+		_, err := w.conn.Write(p)
+		return err
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return err
+	}
+	size := w.opts.chunkSize - gelfChunkHeader
+	for i := 0; i < n; i++ {
+		w.frame = append(w.frame[:0], 0x1e, 0x0f)
+		w.frame = append(w.frame, id[:]...)
+		w.frame = append(w.frame, byte(i), byte(n))
+		if len(p) > size {
+			w.frame = append(w.frame, p[:size]...)
+			p = p[size:]
+		} else {
+			w.frame = append(w.frame, p...)
+		}
+		if _, err := w.conn.Write(w.frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection. Later writes fail with os.ErrClosed.
+func (w *GelfWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// gelfWriterFields are the additional fields set by GelfWriter itself.
+var gelfWriterFields = map[string]bool{
+	"_glog_level": true,
+	"_file":       true,
+	"_line":       true,
+	"_function":   true,
+}
+
+// gelfFieldName returns key as the name of an additional field: '_'
+// followed by letters, digits, '_', '.' and '-'.
+func gelfFieldName(key string) string {
+	b := make([]byte, 0, len(key)+2)
+	b = append(b, '_')
+	if key == "id" {
+		b = append(b, '_')
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '.' || c == '-') {
+			c = '_'
+		}
+		b = append(b, c)
+	}
+	if gelfWriterFields[string(b)] {
+		return "_field" + string(b)
+	}
+	return string(b)
+}
+
+// gelfValue returns v as a number when it is one, and as a string otherwise,
+// the only types of additional fields.
+func gelfValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return v
+	case float32:
+		if f := float64(v); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return v
+		}
+	case float64:
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			return v
+		}
+	}
+	return fmt.Sprint(v)
+}
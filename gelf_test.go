@@ -0,0 +1,197 @@
+package glog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listenGelf(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// decodeGelf decompresses and decodes a message.
+func decodeGelf(t *testing.T, p []byte, decompress func(io.Reader) (io.ReadCloser, error)) map[string]interface{} {
+	t.Helper()
+	if decompress != nil {
+		r, err := decompress(bytes.NewReader(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p, err = ioutil.ReadAll(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(p, &m); err != nil {
+		t.Fatalf("gelf: %v in %q", err, p)
+	}
+	return m
+}
+
+func gunzip(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func TestGelfUDP(t *testing.T) {
+	conn := listenGelf(t)
+	w, err := NewGelfWriter("udp", conn.LocalAddr().String(), GelfHost("host"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(Discard, WithFlags(0), WithPrefix("[p] "), WithMultiWriteCloser(w))
+	defer l.Close()
+	l.WithFields(Fields{"user": "u", "id": 7, "request id": 1.5, "file": "f.txt", "line": 3}).Warning("hello\n")
+
+	m := decodeGelf(t, []byte(readDatagram(t, conn)), gunzip)
+	want := map[string]interface{}{
+		"version":       "1.1",
+		"host":          "host",
+		"short_message": "[p] hello",
+		"level":         4.0,
+		"_glog_level":   "WARNING",
+		"_function":     "github.com/CodyGuo/glog.TestGelfUDP",
+		"_line":         57.0,
+		"_user":         "u",
+		"__id":          7.0,
+		"_request_id":   1.5,
+		"_field_file":   "f.txt",
+		"_field_line":   3.0,
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("gelf key %s: expected %v, got %v", k, v, m[k])
+		}
+	}
+	if file, _ := m["_file"].(string); !strings.HasSuffix(file, "/gelf_test.go") {
+		t.Errorf("gelf file: unexpected %v", m["_file"])
+	}
+	if _, ok := m["full_message"]; ok {
+		t.Errorf("gelf: unexpected full_message %v", m["full_message"])
+	}
+	if ts, _ := m["timestamp"].(float64); time.Since(time.Unix(int64(ts), 0)) > time.Minute {
+		t.Errorf("gelf timestamp: unexpected %v", m["timestamp"])
+	}
+
+	l.SetStacktrace(ERROR)
+	l.Error("failed\ndetails")
+	m = decodeGelf(t, []byte(readDatagram(t, conn)), gunzip)
+	full, _ := m["full_message"].(string)
+	if m["short_message"] != "[p] failed" || !strings.HasPrefix(full, "[p] failed\ndetails\n\tgithub.com/CodyGuo/glog.TestGelfUDP\n\t\t") {
+		t.Errorf("gelf stack: unexpected %q and %q", m["short_message"], full)
+	}
+}
+
+// noise returns n hexadecimal digits of a linear congruential generator,
+// which hardly compress.
+func noise(n int) []byte {
+	p := make([]byte, n)
+	x := uint32(1)
+	for i := range p {
+		x = x*1103515245 + 12345
+		p[i] = "0123456789abcdef"[x>>16%16]
+	}
+	return p
+}
+
+func TestGelfChunks(t *testing.T) {
+	conn := listenGelf(t)
+	w, err := NewGelfWriter("udp", conn.LocalAddr().String(), GelfCompress(GelfZlib), GelfChunkSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	msg := noise(2000)
+	if _, err := w.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	var id []byte
+	var p []byte
+	for i, n := 0, 1; i < n; i++ {
+		chunk := []byte(readDatagram(t, conn))
+		if len(chunk) > 100 || chunk[0] != 0x1e || chunk[1] != 0x0f || int(chunk[10]) != i {
+			t.Fatalf("gelf chunk %d: unexpected header % x", i, chunk[:12])
+		}
+		if i == 0 {
+			id, n = chunk[2:10], int(chunk[11])
+			if n < 2 {
+				t.Fatalf("gelf chunks: expected several, got %d", n)
+			}
+		} else if !bytes.Equal(chunk[2:10], id) || int(chunk[11]) != n {
+			t.Fatalf("gelf chunk %d: header % x does not match % x", i, chunk[:12], id)
+		}
+		p = append(p, chunk[12:]...)
+	}
+	m := decodeGelf(t, p, zlib.NewReader)
+	if m["short_message"] != string(msg) || m["level"] != 6.0 {
+		t.Errorf("gelf chunks: unexpected level %v and message %.20q", m["level"], m["short_message"])
+	}
+
+	if _, err := w.Write(noise(40000)); err == nil || !strings.Contains(err.Error(), "exceeds 128 chunks") {
+		t.Errorf("gelf too large: unexpected error %v", err)
+	}
+}
+
+func TestGelfTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	w, err := NewGelfWriter("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	w.Write([]byte("one"))
+	w.WriteEntry(&Entry{Level: ERROR, Message: "two\x00"})
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"one", "two\x00"} {
+		p, err := r.ReadBytes(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := decodeGelf(t, p[:len(p)-1], nil); m["short_message"] != want {
+			t.Errorf("gelf tcp: expected %q, got %q", want, m["short_message"])
+		}
+	}
+}
+
+func TestGelfFieldName(t *testing.T) {
+	for key, want := range map[string]string{
+		"user":       "_user",
+		"id":         "__id",
+		"user id":    "_user_id",
+		"a.b-c_d":    "_a.b-c_d",
+		"élan":       "___lan",
+		"":           "_",
+		"file":       "_field_file",
+		"glog.level": "_glog.level",
+		"function":   "_field_function",
+	} {
+		if got := gelfFieldName(key); got != want {
+			t.Errorf("gelfFieldName(%q): expected %q, got %q", key, want, got)
+		}
+	}
+}
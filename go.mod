@@ -1,5 +1,3 @@
 module github.com/CodyGuo/glog
 
-go 1.12
-
-require github.com/sirupsen/logrus v1.5.0
+go 1.21
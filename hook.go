@@ -0,0 +1,77 @@
+package glog
+
+// A Hook is fired for every record at one of its Levels, before the record
+// is formatted and written.
+//
+// Fire is called with the logger's lock held, so it must not log through
+// the same logger. Its errors are reported on the Errors channel of the
+// logger.
+type Hook interface {
+	Levels() []Level
+	Fire(e *Entry) error
+}
+
+// An AfterHook is a Hook also fired once the record has been written, with
+// the first error of writing it, or nil. For an asynchronous logger the
+// record has only been queued. AfterWrite is called under the same
+// conditions as Fire and its errors are reported the same way.
+type AfterHook interface {
+	Hook
+	AfterWrite(e *Entry, err error) error
+}
+
+// levelHooks are the hooks of a logger indexed by level.
+type levelHooks map[Level][]Hook
+
+func (hooks levelHooks) add(hook Hook) {
+	for _, level := range hook.Levels() {
+		hooks[level] = append(hooks[level], hook)
+	}
+}
+
+func (hooks levelHooks) fire(e *Entry, errs chan error) {
+	for _, hook := range hooks[e.Level] {
+		if err := hook.Fire(e); err != nil {
+			reportError(errs, err)
+		}
+	}
+}
+
+// fireAfter fires the AfterHooks for e, written with werr.
+func (hooks levelHooks) fireAfter(e *Entry, werr error, errs chan error) {
+	for _, hook := range hooks[e.Level] {
+		if after, ok := hook.(AfterHook); ok {
+			if err := after.AfterWrite(e, werr); err != nil {
+				reportError(errs, err)
+			}
+		}
+	}
+}
+
+// AllLevels lists every level, for hooks that want them all.
+var AllLevels = []Level{TRACE, DEBUG, INFO, NOTICE, WARNING, ERROR, CRITICAL, FATAL, PANIC}
+
+// AddHook registers hook for the levels it declares.
+func (l *Logger) AddHook(hook Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks.add(hook)
+}
+
+// Errors returns a channel reporting the errors of hooks. It is shared with
+// the children of the logger and never closed. When it is not drained the
+// oldest errors are discarded.
+func (l *Logger) Errors() <-chan error {
+	return l.errs
+}
+
+// AddHook registers hook on the standard logger.
+func AddHook(hook Hook) {
+	glog.AddHook(hook)
+}
+
+// Errors returns the channel reporting the hook errors of the standard
+// logger.
+func Errors() <-chan error {
+	return glog.Errors()
+}
@@ -0,0 +1,105 @@
+package glog
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type countHook struct {
+	levels []Level
+	counts map[Level]int
+	err    error
+}
+
+func (h *countHook) Levels() []Level {
+	return h.levels
+}
+
+func (h *countHook) Fire(e *Entry) error {
+	h.counts[e.Level]++
+	return h.err
+}
+
+func TestHook(t *testing.T) {
+	var buf bytes.Buffer
+	hook := &countHook{levels: []Level{ERROR, CRITICAL}, counts: map[Level]int{}}
+	l := New(&buf, WithHook(hook))
+	l.Info("info")
+	l.Error("error")
+	l.Error("error")
+	l.Critical("critical")
+	if hook.counts[INFO] != 0 || hook.counts[ERROR] != 2 || hook.counts[CRITICAL] != 1 {
+		t.Errorf("hook counts: unexpected %v", hook.counts)
+	}
+
+	all := &countHook{levels: AllLevels, counts: map[Level]int{}}
+	l.AddHook(all)
+	l.Debug("filtered by level")
+	l.Notice("notice")
+	if all.counts[DEBUG] != 0 || all.counts[NOTICE] != 1 {
+		t.Errorf("all levels hook counts: unexpected %v", all.counts)
+	}
+}
+
+func TestHookError(t *testing.T) {
+	var buf bytes.Buffer
+	hook := &countHook{levels: AllLevels, counts: map[Level]int{}, err: errors.New("alert failed")}
+	l := New(&buf, WithFlags(0), WithHook(hook))
+	l.Info("still written")
+
+	select {
+	case err := <-l.Errors():
+		if err != hook.err {
+			t.Errorf("hook error: expected %v, got %v", hook.err, err)
+		}
+	default:
+		t.Error("hook error: not reported")
+	}
+	if got, want := buf.String(), "still written\n"; got != want {
+		t.Errorf("hook error: expected the record %q, got %q", want, got)
+	}
+}
+
+type afterHook struct {
+	countHook
+	written []error
+}
+
+func (h *afterHook) AfterWrite(e *Entry, err error) error {
+	h.written = append(h.written, err)
+	return err
+}
+
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestAfterHook(t *testing.T) {
+	var buf bytes.Buffer
+	hook := &afterHook{countHook: countHook{levels: []Level{ERROR}, counts: map[Level]int{}}}
+	l := New(&buf, WithFlags(0), WithHook(hook))
+	l.Info("not hooked")
+	l.Error("written")
+	if len(hook.written) != 1 || hook.written[0] != nil {
+		t.Errorf("after hook: expected one nil error, got %v", hook.written)
+	}
+	if len(l.Errors()) != 0 {
+		t.Errorf("after hook: unexpected errors reported")
+	}
+
+	werr := errors.New("disk full")
+	l.SetOutput(failingWriter{werr})
+	l.Error("lost")
+	if len(hook.written) != 2 || hook.written[1] != werr {
+		t.Errorf("after hook: expected the write error, got %v", hook.written)
+	}
+	if err := <-l.Errors(); err != werr {
+		t.Errorf("after hook: expected %v reported, got %v", werr, err)
+	}
+	if hook.counts[ERROR] != 2 {
+		t.Errorf("after hook: expected Fire before each write, got %v", hook.counts)
+	}
+}
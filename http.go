@@ -0,0 +1,446 @@
+package glog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPOption configures an HTTPWriter.
+type HTTPOption func(*httpOptions)
+
+type httpOptions struct {
+	client     *http.Client
+	header     http.Header
+	batchSize  int
+	batchBytes int
+	interval   time.Duration
+	gzip       bool
+	retries    int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	queue      int
+}
+
+// HTTPClient sets the client sending requests. Default http.DefaultClient.
+func HTTPClient(client *http.Client) HTTPOption {
+	return func(o *httpOptions) {
+		o.client = client
+	}
+}
+
+// HTTPHeader adds a header to requests, such as the Authorization header
+// holding the token of Splunk HEC or the X-Scope-OrgID header of Loki.
+func HTTPHeader(key, value string) HTTPOption {
+	return func(o *httpOptions) {
+		o.header.Add(key, value)
+	}
+}
+
+// HTTPBatchSize sends a batch once it holds n records. Default 1000.
+func HTTPBatchSize(n int) HTTPOption {
+	return func(o *httpOptions) {
+		o.batchSize = n
+	}
+}
+
+// HTTPBatchBytes sends a batch before its encoded records would grow past
+// size bytes. A single larger record is sent on its own. Default 1 MiB.
+func HTTPBatchBytes(size int) HTTPOption {
+	return func(o *httpOptions) {
+		o.batchBytes = size
+	}
+}
+
+// HTTPFlushInterval sends the records waiting in a batch every d, however
+// few. Default one second.
+func HTTPFlushInterval(d time.Duration) HTTPOption {
+	return func(o *httpOptions) {
+		o.interval = d
+	}
+}
+
+// HTTPGzip compresses request bodies with gzip.
+func HTTPGzip() HTTPOption {
+	return func(o *httpOptions) {
+		o.gzip = true
+	}
+}
+
+// HTTPRetry sends a batch again up to retries times after a network error
+// or a 429 or 5xx response, waiting min the first time and twice as long
+// each following time, up to max. A Retry-After header is honored up to
+// max. Default 5 retries from 100ms up to 10s.
+func HTTPRetry(retries int, min, max time.Duration) HTTPOption {
+	return func(o *httpOptions) {
+		o.retries = retries
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// HTTPQueue sets how many full batches may wait while one is being sent.
+// Batches beyond are dropped. Default 8.
+func HTTPQueue(n int) HTTPOption {
+	return func(o *httpOptions) {
+		o.queue = n
+	}
+}
+
+// HTTPStats counts the batches of an HTTPWriter.
+type HTTPStats struct {
+	Batches        uint64 // batches accepted by the server
+	Records        uint64 // records in the accepted batches
+	Retries        uint64 // requests sent again after a failure
+	DroppedBatches uint64 // batches rejected, failing after their retries or overflowing the queue
+	DroppedRecords uint64 // records in the dropped batches
+}
+
+// HTTPWriter is an EntryWriter shipping records in batches to an HTTP
+// endpoint, such as the push API of Loki, the _bulk API of Elasticsearch
+// or the HTTP Event Collector of Splunk, laid out by an HTTPEncoder. Plain
+// writes are sent at INFO.
+//
+// Records are encoded as they are written and batches are posted on a
+// goroutine of their own, so that logging never waits for the network. A
+// batch is sent once it is full, by HTTPBatchSize or HTTPBatchBytes, at
+// every HTTPFlushInterval and by Flush and Close. Failures are reported on
+// the Errors channel and counted by Stats.
+type HTTPWriter struct {
+	url     string
+	encoder HTTPEncoder
+	opts    httpOptions
+
+	mu      sync.Mutex
+	batch   []byte // encoded records of the batch being filled
+	count   int    // number of records in batch
+	closed  bool
+	queue   chan httpBatch
+	pending int // batches queued or being sent
+	idle    *sync.Cond
+	sending sync.WaitGroup // Flush and Close waiting for room in the queue
+	done    chan struct{}
+	errs    chan error
+	stats   HTTPStats // updated atomically
+
+	body bytes.Buffer // request body, used by the sending goroutine
+	zw   *gzip.Writer
+}
+
+type httpBatch struct {
+	records []byte
+	count   int
+}
+
+// NewHTTPWriter returns an HTTPWriter posting the records laid out by
+// encoder to url.
+func NewHTTPWriter(url string, encoder HTTPEncoder, options ...HTTPOption) (*HTTPWriter, error) {
+	if encoder == nil {
+		return nil, errors.New("glog: nil HTTPEncoder")
+	}
+	if _, err := http.NewRequest(http.MethodPost, url, nil); err != nil {
+		return nil, err
+	}
+	w := &HTTPWriter{
+		url:     url,
+		encoder: encoder,
+		opts: httpOptions{
+			client:     http.DefaultClient,
+			header:     http.Header{},
+			batchSize:  1000,
+			batchBytes: 1 << 20,
+			interval:   time.Second,
+			retries:    5,
+			minBackoff: 100 * time.Millisecond,
+			maxBackoff: 10 * time.Second,
+			queue:      8,
+		},
+		done: make(chan struct{}),
+		errs: make(chan error, errorBuffer),
+	}
+	for _, option := range options {
+		option(&w.opts)
+	}
+	if w.opts.interval <= 0 {
+		return nil, fmt.Errorf("glog: non-positive flush interval %v", w.opts.interval)
+	}
+	if w.opts.gzip {
+		w.zw = gzip.NewWriter(nil)
+	}
+	w.queue = make(chan httpBatch, w.opts.queue)
+	w.idle = sync.NewCond(&w.mu)
+	go w.run()
+	return w, nil
+}
+
+func (w *HTTPWriter) wantsCaller() bool {
+	return true
+}
+
+// WriteEntry adds e to the batch being filled.
+func (w *HTTPWriter) WriteEntry(e *Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	n := len(w.batch)
+	if err := w.encoder.Encode(e, &w.batch); err != nil {
+		w.batch = w.batch[:n]
+		return err
+	}
+	if len(w.batch) > w.opts.batchBytes && w.count > 0 {
+		w.batch = w.batch[:n]
+		w.pushLocked()
+		if err := w.encoder.Encode(e, &w.batch); err != nil {
+			w.batch = w.batch[:0]
+			return err
+		}
+	}
+	w.count++
+	if w.count >= w.opts.batchSize || len(w.batch) >= w.opts.batchBytes {
+		w.pushLocked()
+	}
+	return nil
+}
+
+// Write adds p to the batch being filled as a record at INFO.
+func (w *HTTPWriter) Write(p []byte) (int, error) {
+	if err := w.WriteEntry(&Entry{Time: time.Now(), Level: INFO, Message: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// takeLocked returns the batch being filled and starts a new one.
+func (w *HTTPWriter) takeLocked() (httpBatch, bool) {
+	if w.count == 0 {
+		return httpBatch{}, false
+	}
+	b := httpBatch{records: w.batch, count: w.count}
+	w.batch, w.count = nil, 0
+	return b, true
+}
+
+// pushLocked queues the batch being filled, dropping it if the queue is
+// full.
+func (w *HTTPWriter) pushLocked() {
+	b, ok := w.takeLocked()
+	if !ok {
+		return
+	}
+	select {
+	case w.queue <- b:
+		w.pending++
+	default:
+		w.drop(b, errors.New("queue full"))
+	}
+}
+
+func (w *HTTPWriter) run() {
+	defer close(w.done)
+	defer close(w.errs)
+	ticker := time.NewTicker(w.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case b, ok := <-w.queue:
+			if !ok {
+				return
+			}
+			w.send(b)
+			w.mu.Lock()
+			w.pending--
+			if w.pending == 0 {
+				w.idle.Broadcast()
+			}
+			w.mu.Unlock()
+		case <-ticker.C:
+			w.mu.Lock()
+			if !w.closed {
+				w.pushLocked()
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// send posts b, again after a retryable failure, and drops it once the
+// retries are exhausted.
+func (w *HTTPWriter) send(b httpBatch) {
+	body, err := w.encode(b)
+	if err != nil {
+		w.drop(b, err)
+		return
+	}
+	for attempt := 0; ; attempt++ {
+		retryAfter, retry, err := w.post(body)
+		if err == nil {
+			atomic.AddUint64(&w.stats.Batches, 1)
+			atomic.AddUint64(&w.stats.Records, uint64(b.count))
+			return
+		}
+		if !retry || attempt >= w.opts.retries {
+			w.drop(b, err)
+			return
+		}
+		atomic.AddUint64(&w.stats.Retries, 1)
+		time.Sleep(w.backoff(attempt, retryAfter))
+	}
+}
+
+// encode returns the body of the request carrying b.
+func (w *HTTPWriter) encode(b httpBatch) ([]byte, error) {
+	var body []byte
+	if err := w.encoder.Body(b.records, &body); err != nil {
+		return nil, err
+	}
+	if w.zw == nil {
+		return body, nil
+	}
+	w.body.Reset()
+	w.zw.Reset(&w.body)
+	if _, err := w.zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.zw.Close(); err != nil {
+		return nil, err
+	}
+	return w.body.Bytes(), nil
+}
+
+// post sends body once. It reports whether a failure is worth retrying,
+// and after how long the server asked to be retried, if it did.
+func (w *HTTPWriter) post(body []byte) (retryAfter time.Duration, retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	for k, v := range w.opts.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", w.encoder.ContentType())
+	if w.zw != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := w.opts.client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return 0, false, nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	err = errors.New(resp.Status)
+	if msg = bytes.TrimSpace(msg); len(msg) > 0 {
+		err = fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return 0, false, err
+	}
+	if s, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && s >= 0 {
+		retryAfter = time.Duration(s) * time.Second
+	}
+	return retryAfter, true, err
+}
+
+// backoff returns how long to wait before retry attempt+1: an exponential
+// delay with jitter, or retryAfter when longer, bounded by the maximum.
+func (w *HTTPWriter) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	d := w.opts.maxBackoff
+	if attempt < 32 && w.opts.minBackoff<<uint(attempt) < d {
+		d = w.opts.minBackoff << uint(attempt)
+	}
+	if d > 1 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)))
+	}
+	if retryAfter > d {
+		d = retryAfter
+		if d > w.opts.maxBackoff {
+			d = w.opts.maxBackoff
+		}
+	}
+	return d
+}
+
+func (w *HTTPWriter) drop(b httpBatch, err error) {
+	atomic.AddUint64(&w.stats.DroppedBatches, 1)
+	atomic.AddUint64(&w.stats.DroppedRecords, uint64(b.count))
+	reportError(w.errs, fmt.Errorf("glog: batch of %d records to %s dropped: %v", b.count, w.url, err))
+}
+
+// Stats returns the counts of batches sent and dropped so far.
+func (w *HTTPWriter) Stats() HTTPStats {
+	return HTTPStats{
+		Batches:        atomic.LoadUint64(&w.stats.Batches),
+		Records:        atomic.LoadUint64(&w.stats.Records),
+		Retries:        atomic.LoadUint64(&w.stats.Retries),
+		DroppedBatches: atomic.LoadUint64(&w.stats.DroppedBatches),
+		DroppedRecords: atomic.LoadUint64(&w.stats.DroppedRecords),
+	}
+}
+
+// Errors returns a channel reporting the batches dropped. It is closed by
+// Close. When it is not drained the oldest errors are discarded.
+func (w *HTTPWriter) Errors() <-chan error {
+	return w.errs
+}
+
+// Flush sends the batch being filled and waits until every queued batch
+// has been sent or dropped.
+func (w *HTTPWriter) Flush() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return os.ErrClosed
+	}
+	w.queueLocked()
+	for w.pending > 0 {
+		w.idle.Wait()
+	}
+	w.mu.Unlock()
+	return nil
+}
+
+// queueLocked queues the batch being filled, waiting for room in the queue
+// without holding w.mu.
+func (w *HTTPWriter) queueLocked() {
+	if b, ok := w.takeLocked(); ok {
+		w.pending++
+		w.sending.Add(1)
+		w.mu.Unlock()
+		w.queue <- b
+		w.sending.Done()
+		w.mu.Lock()
+	}
+}
+
+// Close sends the batch being filled, waits for the queued batches, their
+// retries included, and stops the sending goroutine. Later writes fail
+// with os.ErrClosed.
+func (w *HTTPWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.queueLocked()
+	w.mu.Unlock()
+	w.sending.Wait()
+	close(w.queue)
+	<-w.done
+	return nil
+}
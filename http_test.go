@@ -0,0 +1,192 @@
+package glog
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// httpRequest is a request received by a test server.
+type httpRequest struct {
+	header http.Header
+	body   string
+}
+
+// httpServer serves the statuses in turn, then 200, and records requests.
+type httpServer struct {
+	mu       sync.Mutex
+	statuses []int
+	requests chan httpRequest
+}
+
+func newHTTPServer(t *testing.T, statuses ...int) (*httpServer, string) {
+	s := &httpServer{statuses: statuses, requests: make(chan httpRequest, 100)}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts.URL
+}
+
+func (s *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil && r.Header.Get("Content-Encoding") == "gzip" {
+		zr, zerr := gzip.NewReader(strings.NewReader(string(body)))
+		if zerr != nil {
+			http.Error(w, zerr.Error(), http.StatusBadRequest)
+			return
+		}
+		body, err = ioutil.ReadAll(zr)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.requests <- httpRequest{r.Header, string(body)}
+	s.mu.Lock()
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	s.mu.Unlock()
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "0")
+	}
+	w.WriteHeader(status)
+}
+
+func (s *httpServer) next(t *testing.T) httpRequest {
+	t.Helper()
+	select {
+	case r := <-s.requests:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("http: no request")
+	}
+	return httpRequest{}
+}
+
+func TestHTTPWriterBatch(t *testing.T) {
+	s, url := newHTTPServer(t)
+	w, err := NewHTTPWriter(url+"/_bulk", ElasticsearchEncoder{}, HTTPBatchSize(3), HTTPGzip(),
+		HTTPHeader("Authorization", "ApiKey k"), HTTPFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(Discard, WithMultiWriteCloser(w))
+	for i := 0; i < 7; i++ {
+		l.Info(i)
+	}
+	for _, n := range []int{3, 3} {
+		r := s.next(t)
+		if got := strings.Count(r.body, "\n"); got != 2*n {
+			t.Errorf("http batch: expected %d records, got %q", n, r.body)
+		}
+		if r.header.Get("Content-Type") != "application/x-ndjson" || r.header.Get("Authorization") != "ApiKey k" {
+			t.Errorf("http batch: unexpected header %v", r.header)
+		}
+	}
+	l.Close()
+	if r := s.next(t); !strings.Contains(r.body, `"message":"6"`) {
+		t.Errorf("http close: expected the last record, got %q", r.body)
+	}
+	if got, want := w.Stats(), (HTTPStats{Batches: 3, Records: 7}); got != want {
+		t.Errorf("http stats: expected %+v, got %+v", want, got)
+	}
+	if _, err := w.Write([]byte("closed")); err != os.ErrClosed {
+		t.Errorf("http closed: expected os.ErrClosed, got %v", err)
+	}
+}
+
+func TestHTTPWriterBytes(t *testing.T) {
+	s, url := newHTTPServer(t)
+	w, err := NewHTTPWriter(url, SplunkEncoder{}, HTTPBatchBytes(160), HTTPFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	// Events take some 75 bytes, so that batches hold two of them.
+	for i := 0; i < 3; i++ {
+		w.Write([]byte("record " + strconv.Itoa(i)))
+	}
+	if r := s.next(t); strings.Count(r.body, "\n") != 2 || len(r.body) > 160 {
+		t.Errorf("http bytes: expected 2 records within 160 bytes, got %q", r.body)
+	}
+	w.Flush()
+	if r := s.next(t); !strings.Contains(r.body, "record 2") || strings.Count(r.body, "\n") != 1 {
+		t.Errorf("http flush: unexpected %q", r.body)
+	}
+}
+
+func TestHTTPWriterInterval(t *testing.T) {
+	s, url := newHTTPServer(t)
+	w, err := NewHTTPWriter(url, LokiEncoder{}, HTTPFlushInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write([]byte("tick"))
+	if r := s.next(t); !strings.Contains(r.body, `"tick"`) {
+		t.Errorf("http interval: unexpected %q", r.body)
+	}
+}
+
+func TestHTTPWriterRetry(t *testing.T) {
+	s, url := newHTTPServer(t, 503, 429, 500, 200, 400, 502, 502, 502, 502)
+	w, err := NewHTTPWriter(url, SplunkEncoder{}, HTTPRetry(3, time.Millisecond, 5*time.Millisecond),
+		HTTPFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// 503, 429 and 500 are retried.
+	w.Write([]byte("retried"))
+	w.Flush()
+	if got, want := w.Stats(), (HTTPStats{Batches: 1, Records: 1, Retries: 3}); got != want {
+		t.Errorf("http retry: expected %+v, got %+v", want, got)
+	}
+	// A 400 is not retried.
+	w.Write([]byte("rejected"))
+	w.Flush()
+	// 502 four times exhausts the retries.
+	w.Write([]byte("failed"))
+	w.Write([]byte("failed"))
+	w.Flush()
+	want := HTTPStats{Batches: 1, Records: 1, Retries: 6, DroppedBatches: 2, DroppedRecords: 3}
+	if got := w.Stats(); got != want {
+		t.Errorf("http drop: expected %+v, got %+v", want, got)
+	}
+	for _, msg := range []string{"400 Bad Request", "502 Bad Gateway"} {
+		if err := <-w.Errors(); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("http errors: expected %q, got %v", msg, err)
+		}
+	}
+	if n := len(s.requests); n != 9 {
+		t.Errorf("http retry: expected 9 requests, got %d", n)
+	}
+}
+
+func TestHTTPWriterBackoff(t *testing.T) {
+	w := &HTTPWriter{opts: httpOptions{minBackoff: 100 * time.Millisecond, maxBackoff: time.Second}}
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		if d := w.backoff(attempt, 0); d < max/2 || d > max {
+			t.Errorf("backoff %d: expected within [%v, %v], got %v", attempt, max/2, max, d)
+		}
+	}
+	if d := w.backoff(0, 500*time.Millisecond); d != 500*time.Millisecond {
+		t.Errorf("backoff: expected Retry-After of 500ms, got %v", d)
+	}
+	if d := w.backoff(0, time.Minute); d != time.Second {
+		t.Errorf("backoff: expected Retry-After bounded by 1s, got %v", d)
+	}
+	if d := w.backoff(100, 0); d < 500*time.Millisecond || d > time.Second {
+		t.Errorf("backoff: expected a bounded delay, got %v", d)
+	}
+}
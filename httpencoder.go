@@ -0,0 +1,197 @@
+package glog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An HTTPEncoder lays out the records shipped by an HTTPWriter.
+//
+// Encode is called with the lock of the HTTPWriter held, so it must not
+// log through a logger writing to it.
+type HTTPEncoder interface {
+	// ContentType returns the media type of request bodies.
+	ContentType() string
+	// Encode appends the encoding of e to buf, which holds the encodings of
+	// the records before it in the batch.
+	Encode(e *Entry, buf *[]byte) error
+	// Body appends to buf the request body of a batch whose records were
+	// encoded to records.
+	Body(records []byte, buf *[]byte) error
+}
+
+// LokiEncoder lays out batches for the push API of Grafana Loki,
+// /loki/api/v1/push. Each record is a line of the stream with Labels and
+// the label level set to the lowercase name of its level. The line is the
+// prefix and message followed by the fields as key=value pairs and the
+// stack trace, as laid out by TextFormatter.
+type LokiEncoder struct {
+	Labels map[string]string
+}
+
+// ContentType returns "application/json".
+func (LokiEncoder) ContentType() string {
+	return "application/json"
+}
+
+// Encode appends e as a stream of one value, after a comma.
+func (enc LokiEncoder) Encode(e *Entry, buf *[]byte) error {
+	labels := make(map[string]string, len(enc.Labels)+1)
+	for k, v := range enc.Labels {
+		labels[k] = v
+	}
+	labels["level"] = strings.ToLower(e.Level.String())
+	line := []byte(strings.TrimRight(e.Prefix+e.Message, "\n"))
+	appendFields(&line, e.Fields)
+	appendStack(&line, e.Stack)
+	stream, err := json.Marshal(struct {
+		Stream map[string]string `json:"stream"`
+		Values [1][2]string      `json:"values"`
+	}{labels, [1][2]string{{strconv.FormatInt(recordTime(e).UnixNano(), 10), string(line)}}})
+	if err != nil {
+		return err
+	}
+	if len(*buf) > 0 {
+		*buf = append(*buf, ',')
+	}
+	*buf = append(*buf, stream...)
+	return nil
+}
+
+// Body appends {"streams":[records]}.
+func (LokiEncoder) Body(records []byte, buf *[]byte) error {
+	*buf = append(*buf, `{"streams":[`...)
+	*buf = append(*buf, records...)
+	*buf = append(*buf, "]}"...)
+	return nil
+}
+
+// ElasticsearchEncoder lays out batches for the _bulk API of Elasticsearch
+// and OpenSearch: for each record, a create action followed by a document
+// with the keys @timestamp, level, message, file, line, function, fields and
+// stack, one JSON object per line. Index names the index or data stream of
+// the documents, or is left empty for the one in the URL, as in
+// http://localhost:9200/logs/_bulk.
+//
+// Documents rejected one by one in a successful response are not reported.
+type ElasticsearchEncoder struct {
+	Index string
+}
+
+// ContentType returns "application/x-ndjson".
+func (ElasticsearchEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+// Encode appends the action and document of e, each followed by a newline.
+func (enc ElasticsearchEncoder) Encode(e *Entry, buf *[]byte) error {
+	type index struct {
+		Index string `json:"_index,omitempty"`
+	}
+	action, err := json.Marshal(struct {
+		Create index `json:"create"`
+	}{index{enc.Index}})
+	if err != nil {
+		return err
+	}
+	doc, err := json.Marshal(struct {
+		Timestamp string `json:"@timestamp"`
+		*httpRecord
+	}{recordTime(e).Format(time.RFC3339Nano), newHTTPRecord(e)})
+	if err != nil {
+		return err
+	}
+	*buf = append(*buf, action...)
+	*buf = append(*buf, '\n')
+	*buf = append(*buf, doc...)
+	*buf = append(*buf, '\n')
+	return nil
+}
+
+// Body appends records.
+func (ElasticsearchEncoder) Body(records []byte, buf *[]byte) error {
+	*buf = append(*buf, records...)
+	return nil
+}
+
+// SplunkEncoder lays out batches for the HTTP Event Collector of Splunk,
+// /services/collector/event, whose token is set with
+// HTTPHeader("Authorization", "Splunk "+token). Each record is an event
+// object with the keys level, message, file, line, function, fields and
+// stack. Index, Source, SourceType and Host, when set, are given to every
+// event.
+type SplunkEncoder struct {
+	Index      string
+	Source     string
+	SourceType string
+	Host       string
+}
+
+// ContentType returns "application/json".
+func (SplunkEncoder) ContentType() string {
+	return "application/json"
+}
+
+// Encode appends the event of e, followed by a newline.
+func (enc SplunkEncoder) Encode(e *Entry, buf *[]byte) error {
+	t := recordTime(e)
+	event, err := json.Marshal(struct {
+		Time       json.Number `json:"time"`
+		Host       string      `json:"host,omitempty"`
+		Source     string      `json:"source,omitempty"`
+		SourceType string      `json:"sourcetype,omitempty"`
+		Index      string      `json:"index,omitempty"`
+		Event      *httpRecord `json:"event"`
+	}{
+		json.Number(fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/1e3)),
+		enc.Host, enc.Source, enc.SourceType, enc.Index,
+		newHTTPRecord(e),
+	})
+	if err != nil {
+		return err
+	}
+	*buf = append(*buf, event...)
+	*buf = append(*buf, '\n')
+	return nil
+}
+
+// Body appends records.
+func (SplunkEncoder) Body(records []byte, buf *[]byte) error {
+	*buf = append(*buf, records...)
+	return nil
+}
+
+// httpRecord is the JSON object of a record shared by the encoders.
+type httpRecord struct {
+	Level    string  `json:"level"`
+	Message  string  `json:"message"`
+	File     string  `json:"file,omitempty"`
+	Line     int     `json:"line,omitempty"`
+	Function string  `json:"function,omitempty"`
+	Fields   Fields  `json:"fields,omitempty"`
+	Stack    []Frame `json:"stack,omitempty"`
+}
+
+func newHTTPRecord(e *Entry) *httpRecord {
+	return &httpRecord{
+		Level:    e.Level.String(),
+		Message:  strings.TrimRight(e.Prefix+e.Message, "\n"),
+		File:     e.File,
+		Line:     e.Line,
+		Function: e.Function(),
+		Fields:   e.Fields,
+		Stack:    e.Stack,
+	}
+}
+
+// recordTime returns the time of e, or the current time for an Entry
+// without one.
+func recordTime(e *Entry) time.Time {
+	if e.Time.IsZero() {
+		return time.Now()
+	}
+	return e.Time
+}
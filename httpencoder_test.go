@@ -0,0 +1,93 @@
+package glog
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var httpEntries = []*Entry{
+	{
+		Time:    time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC),
+		Level:   WARNING,
+		File:    "/src/app/main.go",
+		Line:    12,
+		Prefix:  "[app] ",
+		Message: "disk low\n",
+		Fields:  Fields{"free": 3},
+	},
+	{
+		Time:    time.Date(2024, 5, 6, 7, 8, 10, 0, time.UTC),
+		Level:   ERROR,
+		Message: "failed",
+		Stack:   []Frame{{Function: "main.main", File: "/src/app/main.go", Line: 20}},
+	},
+}
+
+func encodeHTTP(t *testing.T, enc HTTPEncoder) string {
+	t.Helper()
+	var records, body []byte
+	for _, e := range httpEntries {
+		if err := enc.Encode(e, &records); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Body(records, &body); err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestLokiEncoder(t *testing.T) {
+	body := encodeHTTP(t, LokiEncoder{Labels: map[string]string{"app": "demo"}})
+	var push struct {
+		Streams []struct {
+			Stream map[string]string
+			Values [][]string
+		}
+	}
+	if err := json.Unmarshal([]byte(body), &push); err != nil {
+		t.Fatalf("loki: %v in %s", err, body)
+	}
+	if len(push.Streams) != 2 {
+		t.Fatalf("loki: expected 2 streams, got %s", body)
+	}
+	if got, want := push.Streams[0].Stream, map[string]string{"app": "demo", "level": "warning"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loki labels: expected %v, got %v", want, got)
+	}
+	if got, want := push.Streams[0].Values, [][]string{{"1714979289123456789", "[app] disk low free=3"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loki values: expected %q, got %q", want, got)
+	}
+	if got, want := push.Streams[1].Values[0][1], "failed\n\tmain.main\n\t\t/src/app/main.go:20"; got != want {
+		t.Errorf("loki stack: expected %q, got %q", want, got)
+	}
+}
+
+func TestElasticsearchEncoder(t *testing.T) {
+	body := encodeHTTP(t, ElasticsearchEncoder{Index: "logs"})
+	lines := strings.Split(body, "\n")
+	want := []string{
+		`{"create":{"_index":"logs"}}`,
+		`{"@timestamp":"2024-05-06T07:08:09.123456789Z","level":"WARNING","message":"[app] disk low","file":"/src/app/main.go","line":12,"fields":{"free":3}}`,
+		`{"create":{"_index":"logs"}}`,
+		`{"@timestamp":"2024-05-06T07:08:10Z","level":"ERROR","message":"failed","stack":[{"function":"main.main","file":"/src/app/main.go","line":20}]}`,
+		``,
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("elasticsearch: expected\n%s\ngot\n%s", strings.Join(want, "\n"), body)
+	}
+	if body := encodeHTTP(t, ElasticsearchEncoder{}); !strings.HasPrefix(body, `{"create":{}}`+"\n") {
+		t.Errorf("elasticsearch without index: unexpected %q", body)
+	}
+}
+
+func TestSplunkEncoder(t *testing.T) {
+	body := encodeHTTP(t, SplunkEncoder{Index: "main", SourceType: "glog"})
+	want := `{"time":1714979289.123456,"sourcetype":"glog","index":"main","event":{"level":"WARNING","message":"[app] disk low","file":"/src/app/main.go","line":12,"fields":{"free":3}}}` + "\n" +
+		`{"time":1714979290.000000,"sourcetype":"glog","index":"main","event":{"level":"ERROR","message":"failed","stack":[{"function":"main.main","file":"/src/app/main.go","line":20}]}}` + "\n"
+	if body != want {
+		t.Errorf("splunk: expected\n%s\ngot\n%s", want, body)
+	}
+}
@@ -0,0 +1,302 @@
+//go:build linux
+
+package glog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// JournalSocket is the socket of the journald native protocol.
+const JournalSocket = "/run/systemd/journal/socket"
+
+// The memfd_create system call and the file seals, which package syscall
+// lacks on most architectures.
+const (
+	mfdCloexec      = 0x1
+	mfdAllowSealing = 0x2
+	fAddSeals       = 0x409
+	fSealSeal       = 0x1
+	fSealShrink     = 0x2
+	fSealGrow       = 0x4
+	fSealWrite      = 0x8
+)
+
+// memfdCreateTrap is the number of memfd_create by architecture.
+var memfdCreateTrap = map[string]uintptr{
+	"386":      356,
+	"amd64":    319,
+	"arm":      385,
+	"arm64":    279,
+	"loong64":  279,
+	"mips":     4354,
+	"mipsle":   4354,
+	"mips64":   5314,
+	"mips64le": 5314,
+	"ppc64":    360,
+	"ppc64le":  360,
+	"riscv64":  279,
+	"s390x":    350,
+}
+
+// journalWriterFields are the fields set by JournalWriter itself.
+var journalWriterFields = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+	"GLOG_LEVEL":        true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"CODE_FUNC":         true,
+}
+
+// JournalWriter is an EntryWriter sending records to systemd-journald with
+// its native protocol, so that the level, caller and fields of a record are
+// kept as journal fields shown by journalctl -o verbose:
+//
+//   - MESSAGE, the prefix and message,
+//   - PRIORITY, the syslog severity of the level, see SyslogWriter,
+//   - GLOG_LEVEL, the name of the level,
+//   - SYSLOG_IDENTIFIER, the base name of the program,
+//   - CODE_FILE, CODE_LINE and CODE_FUNC, the caller,
+//   - every field, its key uppercased with the characters other than
+//     letters, digits and '_' replaced by '_' and leading '_' and digits,
+//     reserved by journald, removed. A key naming one of the fields above,
+//     such as priority, is prefixed with GLOG_ so that it does not add a
+//     second value to it.
+//
+// Records too large for a datagram are written to a sealed memfd whose
+// descriptor is passed to journald instead, as sd_journal_send does, or to
+// an unlinked file in /dev/shm on kernels without memfd_create.
+type JournalWriter struct {
+	mu     sync.Mutex
+	addr   *net.UnixAddr // journald
+	conn   *net.UnixConn
+	closed bool
+	buf    []byte
+}
+
+// NewJournalWriter returns a JournalWriter sending to the socket at path,
+// JournalSocket if empty. It fails if path is not a socket.
+func NewJournalWriter(path string) (*JournalWriter, error) {
+	if path == "" {
+		path = JournalSocket
+	}
+	if fi, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if fi.Mode()&os.ModeSocket == 0 {
+		return nil, fmt.Errorf("glog: %s is not a socket", path)
+	}
+	// The socket is left unconnected so that descriptors can be passed to
+	// the address, and so that a restarted journald is found again.
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &JournalWriter{addr: &net.UnixAddr{Name: path, Net: "unixgram"}, conn: conn}, nil
+}
+
+func (w *JournalWriter) wantsCaller() bool {
+	return true
+}
+
+// WriteEntry sends e.
+func (w *JournalWriter) WriteEntry(e *Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	priority := 6
+	if info, ok := e.Level.info(); ok {
+		priority = info.syslog
+	}
+	w.buf = w.buf[:0]
+	w.appendHeader(e.Prefix+e.Message, priority)
+	w.appendField("GLOG_LEVEL", e.Level.String())
+	if e.File != "" {
+		w.appendField("CODE_FILE", e.File)
+		w.appendField("CODE_LINE", strconv.Itoa(e.Line))
+	}
+	if function := e.Function(); function != "" {
+		w.appendField("CODE_FUNC", function)
+	}
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := journalFieldName(k)
+		if name == "" {
+			continue
+		}
+		if journalWriterFields[name] {
+			name = "GLOG_" + name
+		}
+		w.appendField(name, fmt.Sprint(e.Fields[k]))
+	}
+	return w.send()
+}
+
+// Write sends p at info.
+func (w *JournalWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	w.buf = w.buf[:0]
+	w.appendHeader(string(p), 6)
+	if err := w.send(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *JournalWriter) appendHeader(msg string, priority int) {
+	w.appendField("MESSAGE", strings.TrimRight(msg, "\n"))
+	w.appendField("PRIORITY", strconv.Itoa(priority))
+	w.appendField("SYSLOG_IDENTIFIER", filepath.Base(os.Args[0]))
+}
+
+// appendField writes NAME=value, or, for a value containing a newline,
+// NAME, a newline, the length of value as a little endian uint64 and value.
+func (w *JournalWriter) appendField(name, value string) {
+	w.buf = append(w.buf, name...)
+	if strings.IndexByte(value, '\n') < 0 {
+		w.buf = append(w.buf, '=')
+	} else {
+		w.buf = append(w.buf, '\n')
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, uint64(len(value)))
+	}
+	w.buf = append(w.buf, value...)
+	w.buf = append(w.buf, '\n')
+}
+
+// send writes w.buf as a datagram, or through a file when it is too large.
+func (w *JournalWriter) send() error {
+	_, _, err := w.conn.WriteMsgUnix(w.buf, nil, w.addr)
+	if err != nil && isMessageSize(err) {
+		err = w.sendFile()
+	}
+	return err
+}
+
+func isMessageSize(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// sendFile writes w.buf to a sealed memfd, or to an unlinked file when
+// memfd_create is not available, and passes its descriptor.
+func (w *JournalWriter) sendFile() error {
+	f, err := w.memfd()
+	if err != nil {
+		if f, err = w.tempFile(); err != nil {
+			return err
+		}
+	}
+	defer f.Close()
+	_, _, err = w.conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), w.addr)
+	return err
+}
+
+// memfd returns a memfd holding w.buf, sealed so that journald can map it
+// knowing it no longer changes.
+func (w *JournalWriter) memfd() (*os.File, error) {
+	trap, ok := memfdCreateTrap[runtime.GOARCH]
+	if !ok {
+		return nil, syscall.ENOSYS
+	}
+	name, err := syscall.BytePtrFromString("journal-glog")
+	if err != nil {
+		return nil, err
+	}
+	fd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(name)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	f := os.NewFile(fd, "journal-glog")
+	if _, err := f.Write(w.buf); err != nil {
+		f.Close()
+		return nil, err
+	}
+	seals := fSealSeal | fSealShrink | fSealGrow | fSealWrite
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, fAddSeals, uintptr(seals)); errno != 0 {
+		f.Close()
+		return nil, errno
+	}
+	return f, nil
+}
+
+// tempFile returns an unlinked file in /dev/shm holding w.buf.
+func (w *JournalWriter) tempFile() (*os.File, error) {
+	dir := "/dev/shm"
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		dir = os.TempDir()
+	}
+	f, err := ioutil.TempFile(dir, "journal.")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Write(w.buf); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Close closes the connection. Later writes fail with os.ErrClosed.
+func (w *JournalWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// journalFieldName returns key as a journal field name: uppercase letters,
+// digits and '_', not starting with '_' or a digit, at most 64 bytes. It
+// returns "" if nothing is left.
+func journalFieldName(key string) string {
+	b := make([]byte, 0, len(key))
+	for i := 0; i < len(key) && len(b) < 64; i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z':
+			c -= 'a' - 'A'
+		case 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9', c == '_':
+			if len(b) == 0 {
+				continue
+			}
+		default:
+			if len(b) == 0 {
+				continue
+			}
+			c = '_'
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
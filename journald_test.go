@@ -0,0 +1,151 @@
+//go:build linux
+
+package glog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// parseJournal decodes the fields of a native protocol message.
+func parseJournal(t *testing.T, p []byte) map[string]string {
+	t.Helper()
+	fields := map[string]string{}
+	for len(p) > 0 {
+		i := bytes.IndexAny(p, "=\n")
+		if i < 0 {
+			t.Fatalf("journal: truncated message %q", p)
+		}
+		name := string(p[:i])
+		if _, ok := fields[name]; ok {
+			t.Errorf("journal: field %s sent twice", name)
+		}
+		if p[i] == '=' {
+			end := bytes.IndexByte(p, '\n')
+			fields[name] = string(p[i+1 : end])
+			p = p[end+1:]
+			continue
+		}
+		n := binary.LittleEndian.Uint64(p[i+1:])
+		fields[name] = string(p[i+9 : i+9+int(n)])
+		p = p[i+9+int(n)+1:]
+	}
+	return fields
+}
+
+func listenJournal(t *testing.T) (*net.UnixConn, string) {
+	dir, err := ioutil.TempDir("", "glog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn, path
+}
+
+func TestJournalWriter(t *testing.T) {
+	conn, path := listenJournal(t)
+	w, err := NewJournalWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(Discard, WithFlags(0), WithPrefix("[p] "), WithMultiWriteCloser(w))
+	defer l.Close()
+	l.WithFields(Fields{"request-id": 7, "_trusted": "x", "query": "a\nb", "priority": "high", "code_line": 1}).Warning("hello\n")
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := parseJournal(t, buf[:n])
+	want := map[string]string{
+		"MESSAGE":        "[p] hello",
+		"PRIORITY":       "4",
+		"GLOG_LEVEL":     "WARNING",
+		"CODE_FUNC":      "github.com/CodyGuo/glog.TestJournalWriter",
+		"REQUEST_ID":     "7",
+		"TRUSTED":        "x",
+		"QUERY":          "a\nb",
+		"GLOG_PRIORITY":  "high",
+		"GLOG_CODE_LINE": "1",
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("journal field %s: expected %q, got %q", k, v, fields[k])
+		}
+	}
+	if !strings.HasSuffix(fields["CODE_FILE"], "/journald_test.go") || fields["CODE_LINE"] != "68" {
+		t.Errorf("journal caller: unexpected %s:%s", fields["CODE_FILE"], fields["CODE_LINE"])
+	}
+}
+
+func TestJournalWriterLarge(t *testing.T) {
+	conn, path := listenJournal(t)
+	w, err := NewJournalWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	large := strings.Repeat("x", 1<<20)
+	if _, err := w.Write([]byte(large)); err != nil {
+		t.Fatal(err)
+	}
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := conn.ReadMsgUnix(nil, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("journal large: expected a control message, got %v (%v)", msgs, err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("journal large: expected a descriptor, got %v (%v)", fds, err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "journal")
+	defer f.Close()
+	// F_GET_SEALS: the memfd can no longer be written.
+	if seals, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), 0x40a, 0); errno != 0 || seals&fSealWrite == 0 {
+		t.Errorf("journal large: expected a sealed memfd, got seals %#x (%v)", seals, errno)
+	}
+	f.Seek(0, 0)
+	p, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parseJournal(t, p)["MESSAGE"]; got != large {
+		t.Errorf("journal large: message of %d bytes, expected %d", len(got), len(large))
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	for key, want := range map[string]string{
+		"user":       "USER",
+		"request-id": "REQUEST_ID",
+		"__cursor":   "CURSOR",
+		"9lives":     "LIVES",
+		"élan":       "LAN",
+		"---":        "",
+	} {
+		if got := journalFieldName(key); got != want {
+			t.Errorf("journalFieldName(%q): expected %q, got %q", key, want, got)
+		}
+	}
+}
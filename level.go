@@ -1,5 +1,17 @@
 package glog
 
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
 const (
 	TRACE Level = iota
 	DEBUG
@@ -12,27 +24,218 @@ const (
 	PANIC
 )
 
-var levelName = []string{
-	"TRACE",
-	"DEBUG",
-	"INFO",
-	"NOTICE",
-	"WARNING",
-	"ERROR",
-	"CRITICAL",
-	"FATAL",
-	"PANIC",
+type Level uint32
+
+// rankStep spaces the ranks of the built-in levels, leaving room for the
+// levels registered between them.
+const rankStep = 1 << 16
+
+// levelInfo describes a level. Levels are ordered by rank, not by value, so
+// that registered levels can sit between the built-in ones.
+type levelInfo struct {
+	name   string
+	short  string
+	rank   uint32
+	color  string
+	syslog int
+	slog   slog.Level
 }
 
-type Level uint32
+var builtinLevels = []levelInfo{
+	TRACE:    {name: "TRACE", color: "90", syslog: 7, slog: slog.LevelDebug - 4},
+	DEBUG:    {name: "DEBUG", color: "36", syslog: 7, slog: slog.LevelDebug},
+	INFO:     {name: "INFO", color: "32", syslog: 6, slog: slog.LevelInfo},
+	NOTICE:   {name: "NOTICE", color: "34", syslog: 5, slog: slog.LevelInfo + 2},
+	WARNING:  {name: "WARNING", color: "33", syslog: 4, slog: slog.LevelWarn},
+	ERROR:    {name: "ERROR", color: "31", syslog: 3, slog: slog.LevelError},
+	CRITICAL: {name: "CRITICAL", color: "1;31", syslog: 2, slog: slog.LevelError + 4},
+	FATAL:    {name: "FATAL", color: "1;35", syslog: 1, slog: slog.LevelError + 8},
+	PANIC:    {name: "PANIC", color: "1;41", syslog: 0, slog: slog.LevelError + 12},
+}
+
+// levelTable holds the levelInfo of every level, indexed by Level. It is
+// replaced, never modified, by RegisterLevel so that it can be read without
+// locking. It is set up by a variable initializer rather than init so that
+// levels can be registered by the initializers of other variables.
+var (
+	levelMu    sync.Mutex
+	levelTable = newLevelTable()
+)
+
+func newLevelTable() *atomic.Pointer[[]levelInfo] {
+	table := make([]levelInfo, len(builtinLevels))
+	for i, info := range builtinLevels {
+		info.rank = uint32(i) * rankStep
+		table[i] = info
+	}
+	var p atomic.Pointer[[]levelInfo]
+	p.Store(&table)
+	return &p
+}
+
+// info returns the description of l, if l is a known level.
+func (l Level) info() (*levelInfo, bool) {
+	table := *levelTable.Load()
+	if int(l) >= len(table) {
+		return nil, false
+	}
+	return &table[l], true
+}
+
+// rank orders l among the levels. Unknown levels rank above all others.
+func (l Level) rank() uint32 {
+	if info, ok := l.info(); ok {
+		return info.rank
+	}
+	return math.MaxUint32
+}
+
+// Less reports whether l is below other. Use it rather than < to compare
+// levels, which are ordered by severity rather than by value once levels
+// have been registered.
+func (l Level) Less(other Level) bool {
+	return l.rank() < other.rank()
+}
+
+// atLeast reports whether l is min or above.
+func (l Level) atLeast(min Level) bool {
+	return l.rank() >= min.rank()
+}
 
 func (l Level) String() string {
-	if l > PANIC {
-		return "INVALID"
+	if info, ok := l.info(); ok {
+		return info.name
 	}
-	return levelName[l]
+	return "INVALID"
 }
 
 func (l Level) Len() uint8 {
 	return uint8(len(l.String()))
 }
+
+// LevelSpec describes a level added by RegisterLevel.
+type LevelSpec struct {
+	// Name is the full name of the level, such as "AUDIT".
+	Name string
+	// Short replaces Name when it is longer than the length set by
+	// WithLevelLength. It is truncated in turn if needed.
+	Short string
+	// Above is the level immediately below the new one.
+	Above Level
+	// Color is the ANSI SGR parameters the level is colored with, such as
+	// "1;35". The color of Above is used when it is empty.
+	Color string
+	// Syslog is the syslog severity of the level, from 1 (alert) to 7
+	// (debug). The severity of Above is used when it is 0.
+	Syslog int
+}
+
+// RegisterLevel adds a level ranked right above spec.Above and below the
+// levels that were above it, and returns it. The new level can be logged
+// with Log and Logf, parsed by ParseLevel and is added to AllLevels.
+// Levels should be registered during initialization, before logging.
+func RegisterLevel(spec LevelSpec) (Level, error) {
+	name := strings.ToUpper(spec.Name)
+	if name == "" {
+		return 0, errors.New("glog: level name is empty")
+	}
+	if spec.Syslog < 0 || spec.Syslog > 7 {
+		return 0, fmt.Errorf("glog: invalid syslog severity %d for level %s", spec.Syslog, name)
+	}
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	if _, err := ParseLevel(name); err == nil {
+		return 0, fmt.Errorf("glog: level %s is already registered", name)
+	}
+	table := *levelTable.Load()
+	above, ok := spec.Above.info()
+	if !ok {
+		return 0, fmt.Errorf("glog: unknown level %d for level %s to be above", uint32(spec.Above), name)
+	}
+	next := uint64(above.rank) + rankStep
+	for _, info := range table {
+		if info.rank > above.rank && uint64(info.rank) < next {
+			next = uint64(info.rank)
+		}
+	}
+	if next-uint64(above.rank) < 2 || next >= math.MaxUint32 {
+		return 0, fmt.Errorf("glog: no room for level %s above %s", name, above.name)
+	}
+	info := levelInfo{
+		name:   name,
+		short:  strings.ToUpper(spec.Short),
+		rank:   uint32((uint64(above.rank) + next) / 2),
+		color:  spec.Color,
+		syslog: spec.Syslog,
+		slog:   above.slog + 1,
+	}
+	if info.color == "" {
+		info.color = above.color
+	}
+	if info.syslog == 0 {
+		info.syslog = above.syslog
+	}
+	level := Level(len(table))
+	updated := append(table[:len(table):len(table)], info)
+	levelTable.Store(&updated)
+
+	all := append(AllLevels[:len(AllLevels):len(AllLevels)], level)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Less(all[j]) })
+	AllLevels = all
+	return level, nil
+}
+
+// levelAlias maps the alternative names accepted by ParseLevel.
+var levelAlias = map[string]Level{
+	"WARN": WARNING,
+	"ERR":  ERROR,
+	"CRIT": CRITICAL,
+}
+
+// ParseLevel returns the level named s, ignoring case. Besides the names
+// returned by String it accepts the aliases WARN, ERR and CRIT.
+func ParseLevel(s string) (Level, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	for i, info := range *levelTable.Load() {
+		if info.name == name {
+			return Level(i), nil
+		}
+	}
+	if l, ok := levelAlias[name]; ok {
+		return l, nil
+	}
+	return 0, fmt.Errorf("glog: unknown level %q", s)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l Level) MarshalText() ([]byte, error) {
+	info, ok := l.info()
+	if !ok {
+		return nil, fmt.Errorf("glog: invalid level %d", uint32(l))
+	}
+	return []byte(info.name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler with ParseLevel.
+func (l *Level) UnmarshalText(text []byte) error {
+	v, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = v
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, encoding l as its name.
+func (l Level) MarshalJSON() ([]byte, error) {
+	text, err := l.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return strconv.AppendQuote(nil, string(text)), nil
+}
+
+// Set implements flag.Value with ParseLevel.
+func (l *Level) Set(s string) error {
+	return l.UnmarshalText([]byte(s))
+}
@@ -1,6 +1,12 @@
 package glog
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"testing"
+)
 
 type levelTester struct {
 	name  string
@@ -30,3 +36,105 @@ func TestLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]Level{"warn": WARNING, "Warning": WARNING, "ERR": ERROR, "error": ERROR, " trace ": TRACE, "panic": PANIC} {
+		if got, err := ParseLevel(s); err != nil || got != want {
+			t.Errorf("ParseLevel(%q): expected %v, got %v (%v)", s, want, got, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Errorf("ParseLevel: expected an error for an unknown level")
+	}
+}
+
+func TestLevelMarshal(t *testing.T) {
+	var config struct {
+		Level Level `json:"level"`
+	}
+	if err := json.Unmarshal([]byte(`{"level":"warn"}`), &config); err != nil || config.Level != WARNING {
+		t.Fatalf("unmarshal: expected WARNING, got %v (%v)", config.Level, err)
+	}
+	b, err := json.Marshal(config)
+	if err != nil || string(b) != `{"level":"WARNING"}` {
+		t.Errorf("marshal: unexpected %s (%v)", b, err)
+	}
+	if _, err := json.Marshal(Level(42)); err == nil {
+		t.Errorf("marshal: expected an error for an invalid level")
+	}
+}
+
+func TestLevelFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	level := INFO
+	fs.Var(&level, "level", "log level")
+	if err := fs.Parse([]string{"-level", "err"}); err != nil || level != ERROR {
+		t.Errorf("flag: expected ERROR, got %v (%v)", level, err)
+	}
+	fs.SetOutput(ioutil.Discard)
+	if err := fs.Parse([]string{"-level", "loud"}); err == nil {
+		t.Errorf("flag: expected an error for an unknown level")
+	}
+}
+
+var (
+	AUDIT    = mustRegisterLevel(LevelSpec{Name: "audit", Short: "AUD", Above: NOTICE})
+	SECURITY = mustRegisterLevel(LevelSpec{Name: "SECURITY", Short: "SEC", Above: ERROR, Color: "1;33", Syslog: 2})
+)
+
+func mustRegisterLevel(spec LevelSpec) Level {
+	level, err := RegisterLevel(spec)
+	if err != nil {
+		panic(err)
+	}
+	return level
+}
+
+func TestRegisterLevel(t *testing.T) {
+	order := []Level{NOTICE, AUDIT, WARNING, ERROR, SECURITY, CRITICAL}
+	for i := 1; i < len(order); i++ {
+		if !order[i-1].Less(order[i]) {
+			t.Errorf("register: expected %s below %s", order[i-1], order[i])
+		}
+	}
+	var all []Level
+	for _, level := range AllLevels {
+		if level == AUDIT || level == SECURITY || level == WARNING {
+			all = append(all, level)
+		}
+	}
+	if len(all) != 3 || all[0] != AUDIT || all[1] != WARNING || all[2] != SECURITY {
+		t.Errorf("register: AllLevels out of order: %v", AllLevels)
+	}
+	if got, err := ParseLevel("Audit"); err != nil || got != AUDIT {
+		t.Errorf("register: ParseLevel expected AUDIT, got %v (%v)", got, err)
+	}
+	if info, _ := AUDIT.info(); info.color != "34" || info.syslog != 5 {
+		t.Errorf("register: AUDIT should inherit from NOTICE, got %+v", info)
+	}
+	for _, spec := range []LevelSpec{{Name: "warn", Above: INFO}, {Name: "", Above: INFO}, {Name: "x", Above: Level(1000)}, {Name: "y", Above: INFO, Syslog: 9}} {
+		if _, err := RegisterLevel(spec); err == nil {
+			t.Errorf("register %+v: expected an error", spec)
+		}
+	}
+}
+
+func TestRegisteredLevelOutput(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lmsglevel), WithLevel(AUDIT), WithLevelLength(3))
+	l.Notice("hidden")
+	l.Log(AUDIT, "audit")
+	l.Logf(SECURITY, "security %d", 1)
+	l.Warning("warning")
+	want := "[AUD] audit\n[SEC] security 1\n[WAR] warning\n"
+	if got := buf.String(); got != want {
+		t.Errorf("registered output: expected %q, got %q", want, got)
+	}
+	buf.Reset()
+	l.SetLevel(SECURITY)
+	l.Error("hidden")
+	l.Critical("critical")
+	if got := buf.String(); got != "[CRI] critical\n" {
+		t.Errorf("registered filter: unexpected %q", got)
+	}
+}
@@ -1,13 +1,12 @@
 package glog
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,54 +20,127 @@ const (
 	Lmsgprefix                    // move the "prefix" from the beginning of the line to before the message
 	Lmsglevel                     // log level: [INFO]
 	Lmsgjson                      // log json format: {"message":"hello json"}
+	Lcolor                        // color the log level of terminals by level: \x1b[32m[INFO]\x1b[0m
+	Lcolorline                    // color the whole line of terminals by level
+	Lfuncname                     // calling function: glog.(*Logger).Info
+	Lpackage                      // package path of the calling function: github.com/CodyGuo/glog
 	LstdFlags     = Ldate | Ltime // initial values for the standard logger
 	LglogFlags    = LstdFlags | Lmicroseconds | Lshortfile | Lmsgprefix | Lmsglevel
 )
 
+// callerFlags are the flags needing the caller of a record.
+const callerFlags = Llongfile | Lshortfile | Lfuncname | Lpackage
+
 var glog = New(os.Stderr, WithCallDepth(4), WithFlags(LglogFlags))
 
 var Discard io.Writer = ioutil.Discard
 
+// Fields are key/value pairs attached to every record written by a Logger
+// derived with WithFields or WithField.
 type Fields map[string]interface{}
 
 type Logger struct {
-	once        *sync.Once
+	*core
+	once      *sync.Once
+	callDepth int
+	fields    Fields
+}
+
+// core is the state a Logger shares with the children derived from it,
+// so that they all write through the same output under the same lock.
+type core struct {
 	mu          sync.Mutex
 	out         io.Writer
 	closers     []io.Closer
 	prefix      string
 	flag        int
-	callDepth   int
 	level       Level
 	levelLength uint8
+	formatter   Formatter
+	hooks       levelHooks
+	errs        chan error // hook errors
+	helpers     *helperSet
+	sinks       []*sink
+	async       *asyncWriter
+	palette     map[Level]string
+	timeFormat  string
+	stacktrace  Level
+	stackOn     bool // whether stacktrace is set
+	location    *time.Location
+	verbosity   int32
+	vmodule     atomic.Pointer[vmodule]
 	buf         []byte
 }
 
 func New(out io.Writer, options ...Option) *Logger {
 	l := &Logger{
-		out:       out,
+		core: &core{
+			out:     out,
+			prefix:  "",
+			flag:    LstdFlags,
+			level:   INFO,
+			hooks:   levelHooks{},
+			errs:    make(chan error, errorBuffer),
+			helpers: &helperSet{},
+		},
 		once:      &sync.Once{},
-		prefix:    "",
-		flag:      LstdFlags,
 		callDepth: 3,
-		level:     INFO,
 	}
 	for _, option := range options {
 		option(l)
 	}
 	return l
 }
+
+// WithFields returns a child Logger that adds fields to every record it writes.
+// The child shares the output, flags, prefix and level of l; fields already
+// carried by l are kept unless overridden.
+func (l *Logger) WithFields(fields Fields) *Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{
+		core:      l.core,
+		once:      &sync.Once{},
+		callDepth: l.callDepth,
+		fields:    merged,
+	}
+}
+
+// WithField returns a child Logger that adds key=value to every record it writes.
+func (l *Logger) WithField(key string, value interface{}) *Logger {
+	return l.WithFields(Fields{key: value})
+}
+
+// SetOutput replaces the outputs following the level of the logger,
+// EntryWriters added with AddOutput included, with w. Leveled outputs are
+// kept.
 func (l *Logger) SetOutput(w io.Writer) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.dropFollowingLocked()
 	l.out = w
 }
 
 func (l *Logger) AddOutput(writers ...io.Writer) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	writers = append(writers, l.out)
-	l.out = io.MultiWriter(writers...)
+	var plain []io.Writer
+	for _, w := range writers {
+		if keptApart(w) {
+			l.addLocked(w)
+		} else {
+			plain = append(plain, w)
+		}
+	}
+	plain = append(plain, l.plainOutLocked())
+	l.out = io.MultiWriter(plain...)
 }
 
 func (l *Logger) SetFile(name string, flag int, perm os.FileMode) error {
@@ -78,6 +150,7 @@ func (l *Logger) SetFile(name string, flag int, perm os.FileMode) error {
 	if err != nil {
 		return err
 	}
+	l.dropFollowingLocked()
 	l.closers = append(l.closers, f)
 	l.out = f
 	return nil
@@ -91,13 +164,14 @@ func (l *Logger) AddFile(name string, flag int, perm os.FileMode) error {
 		return err
 	}
 	l.closers = append(l.closers, f)
-	l.out = io.MultiWriter(l.out, f)
+	l.out = io.MultiWriter(l.plainOutLocked(), f)
 	return nil
 }
 
 func (l *Logger) SetWriteCloser(writeCloser io.WriteCloser) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.dropFollowingLocked()
 	l.closers = append(l.closers, writeCloser)
 	l.out = writeCloser
 }
@@ -107,13 +181,41 @@ func (l *Logger) AddWriteCloser(writeClosers ...io.WriteCloser) {
 	defer l.mu.Unlock()
 	for _, writeCloser := range writeClosers {
 		l.closers = append(l.closers, writeCloser)
-		l.out = io.MultiWriter(l.out, writeCloser)
+		l.addLocked(writeCloser)
+	}
+}
+
+// Flush waits until the records queued by an asynchronous logger have been
+// written. It returns immediately for a synchronous logger.
+func (l *Logger) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.async != nil {
+		l.async.flush()
+	}
+	return nil
+}
+
+// Dropped returns the number of records an asynchronous logger has
+// discarded because of its overflow policy.
+func (l *Logger) Dropped() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.async == nil {
+		return 0
 	}
+	return atomic.LoadUint64(&l.async.dropped)
 }
 
+// Close drains the queue of an asynchronous logger, which writes
+// synchronously from then on, and closes the closers of the logger.
 func (l *Logger) Close() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	if l.async != nil {
+		l.async.close()
+		l.async = nil
+	}
 	if len(l.closers) == 0 {
 		return os.ErrInvalid
 	}
@@ -146,196 +248,126 @@ func itoa(buf *[]byte, i int, wid int) {
 	*buf = append(*buf, b[bp:]...)
 }
 
-// formatHeader writes log header to buf in following order:
-//   * l.prefix (if it's not blank and Lmsgprefix is unset),
-//   * date and/or time (if corresponding flags are provided),
-//   * file and line number (if corresponding flags are provided),
-//   * l.prefix (if it's not blank and Lmsgprefix is set).
-func (l *Logger) formatHeader(buf *[]byte, t time.Time, file string, line int, level Level) {
-	if l.flag&Lmsgprefix == 0 {
-		*buf = append(*buf, l.prefix...)
+func (l *Logger) Output(level Level, format string, v ...interface{}) error {
+	return l.output(0, level, format, v)
+}
+
+// output takes v as a slice rather than variadically so that log, which has
+// no format, does not read as a misused printf wrapper. depth is the number
+// of frames to skip above the call depth of the logger.
+func (l *Logger) output(depth int, level Level, format string, v []interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.enabledLocked(level) {
+		return nil
 	}
-	if l.flag&(Ldate|Ltime|Lmicroseconds) != 0 {
-		if l.flag&LUTC != 0 {
-			t = t.UTC()
-		}
-		if l.flag&Ldate != 0 {
-			year, month, day := t.Date()
-			itoa(buf, year, 4)
-			*buf = append(*buf, '/')
-			itoa(buf, int(month), 2)
-			*buf = append(*buf, '/')
-			itoa(buf, day, 2)
-			*buf = append(*buf, ' ')
-		}
-		if l.flag&(Ltime|Lmicroseconds) != 0 {
-			hour, min, sec := t.Clock()
-			itoa(buf, hour, 2)
-			*buf = append(*buf, ':')
-			itoa(buf, min, 2)
-			*buf = append(*buf, ':')
-			itoa(buf, sec, 2)
-			if l.flag&Lmicroseconds != 0 {
-				*buf = append(*buf, '.')
-				itoa(buf, t.Nanosecond()/1e3, 6)
-			}
-			*buf = append(*buf, ' ')
+	e := newEntry()
+	defer e.release()
+	e.Logger = l
+	e.Time = time.Now()
+	e.Level = level
+	e.Prefix = l.prefix
+	e.Fields = l.fields
+	caller, stack := l.flag&callerFlags != 0 || l.wantsCallerLocked(), l.stackLocked(level)
+	if caller || stack {
+		// Release lock while getting caller info - it's expensive.
+		l.mu.Unlock()
+		if caller {
+			e.setCaller(l.callDepth+depth+1, l.helpers)
 		}
-	}
-	if l.flag&(Lshortfile|Llongfile) != 0 {
-		if l.flag&Lshortfile != 0 {
-			short := file
-			for i := len(file) - 1; i > 0; i-- {
-				if file[i] == '/' {
-					short = file[i+1:]
-					break
-				}
-			}
-			file = short
+		if stack {
+			e.setStack(l.callDepth+depth+1, l.helpers)
 		}
-		*buf = append(*buf, file...)
-		*buf = append(*buf, ':')
-		itoa(buf, line, -1)
-		*buf = append(*buf, ": "...)
-	}
-	if l.flag&Lmsgprefix != 0 {
-		*buf = append(*buf, l.prefix...)
+		l.mu.Lock()
 	}
-	if l.flag&Lmsglevel != 0 {
-		s := level.String()
-		end := level.Len()
-		if 0 < l.levelLength && l.levelLength < end {
-			end = l.levelLength
-			s = s[:end]
-		}
-		*buf = append(*buf, '[')
-		*buf = append(*buf, s...)
-		*buf = append(*buf, "] "...)
+	if format == "" {
+		e.Message = sprint(v)
+	} else {
+		e.Message = fmt.Sprintf(format, v...)
 	}
+	return l.emitLocked(e)
 }
 
-func (l *Logger) jsonFormatHeader(buf *[]byte, t time.Time, file string, line int, level Level, s string) {
-	var jsonData = struct {
-		Time    string `json:"time,omitempty"`
-		Level   string `json:"level,omitempty"`
-		File    string `json:"file,omitempty"`
-		Message string `json:"message"`
-	}{}
-	if l.flag&(Ldate|Ltime|Lmicroseconds) != 0 {
-		if l.flag&LUTC != 0 {
-			t = t.UTC()
-		}
-		if l.flag&Ldate != 0 {
-			year, month, day := t.Date()
-			itoa(buf, year, 4)
-			*buf = append(*buf, '/')
-			itoa(buf, int(month), 2)
-			*buf = append(*buf, '/')
-			itoa(buf, day, 2)
-			*buf = append(*buf, ' ')
-		}
-		if l.flag&(Ltime|Lmicroseconds) != 0 {
-			hour, min, sec := t.Clock()
-			itoa(buf, hour, 2)
-			*buf = append(*buf, ':')
-			itoa(buf, min, 2)
-			*buf = append(*buf, ':')
-			itoa(buf, sec, 2)
-			if l.flag&Lmicroseconds != 0 {
-				*buf = append(*buf, '.')
-				itoa(buf, t.Nanosecond()/1e3, 6)
-			}
-		}
-		jsonData.Time = string(*buf)
-		*buf = (*buf)[:0]
+// emitLocked fires the hooks for e and writes it to every output enabled
+// at its level, then fires the AfterHooks with the result.
+func (l *Logger) emitLocked(e *Entry) error {
+	l.hooks.fire(e, l.errs)
+	var err error
+	if e.Level.atLeast(l.level) {
+		err = l.writeLocked(l.out, l.formatterLocked(), e)
 	}
-	if l.flag&(Lshortfile|Llongfile) != 0 {
-		if l.flag&Lshortfile != 0 {
-			short := file
-			for i := len(file) - 1; i > 0; i-- {
-				if file[i] == '/' {
-					short = file[i+1:]
-					break
-				}
-			}
-			file = short
+	for _, s := range l.sinks {
+		if !s.enabled(l, e.Level) {
+			continue
 		}
-		*buf = append(*buf, file...)
-		*buf = append(*buf, ':')
-		itoa(buf, line, -1)
-		jsonData.File = string(*buf)
-		*buf = (*buf)[:0]
-	}
-	if l.flag&Lmsglevel != 0 {
-		s := level.String()
-		end := level.Len()
-		if 0 < l.levelLength && l.levelLength < end {
-			end = l.levelLength
-			s = s[:end]
+		f := s.formatter
+		if f == nil {
+			f = l.formatterLocked()
+		}
+		if serr := l.writeLocked(s.w, f, e); err == nil {
+			err = serr
 		}
-		*buf = append(*buf, s...)
-		jsonData.Level = string(*buf)
-		*buf = (*buf)[:0]
 	}
-	*buf = append(*buf, l.prefix...)
-	*buf = append(*buf, s...)
-	jsonData.Message = string(*buf)
-	*buf = (*buf)[:0]
+	l.hooks.fireAfter(e, err, l.errs)
+	return err
+}
 
-	jsonBytes, err := json.Marshal(&jsonData)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "json format failed, error: %v\n", err)
-		return
+// sprint is fmt.Sprint without allocating for a single string.
+func sprint(v []interface{}) string {
+	if len(v) == 1 {
+		if s, ok := v[0].(string); ok {
+			return s
+		}
 	}
-	*buf = append(*buf, jsonBytes...)
+	return fmt.Sprint(v...)
 }
 
-func (l *Logger) Output(level Level, format string, v ...interface{}) error {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	if l.level > level {
-		return nil
-	}
-	now := time.Now()
-	var file string
-	var line int
-	if l.flag&(Lshortfile|Llongfile) != 0 {
-		// Release lock while getting caller info - it's expensive.
-		l.mu.Unlock()
-		var ok bool
-		_, file, line, ok = runtime.Caller(l.callDepth)
-		if !ok {
-			file = "???"
-			line = 0
+// writeLocked formats e with f and writes it to w, or queues it for w when
+// the logger is asynchronous. An EntryWriter is handed e itself.
+func (l *Logger) writeLocked(w io.Writer, f Formatter, e *Entry) error {
+	if ew, ok := w.(EntryWriter); ok {
+		if l.async != nil {
+			l.async.enqueueEntry(ew, e)
+			return nil
 		}
-		l.mu.Lock()
+		return ew.WriteEntry(e)
 	}
+	e.color = l.colorLocked(w, e.Level)
 	l.buf = l.buf[:0]
-	var s string
-	if format == "" {
-		s = fmt.Sprint(v...)
-	} else {
-		s = fmt.Sprintf(format, v...)
-	}
-	if l.flag&Lmsgjson != 0 {
-		l.jsonFormatHeader(&l.buf, now, file, line, level, s)
-	} else {
-		l.formatHeader(&l.buf, now, file, line, level)
-		l.buf = append(l.buf, s...)
+	if err := f.Format(e, &l.buf); err != nil {
+		fmt.Fprintf(os.Stderr, "glog: %v\n", err)
+		return err
 	}
-	if len(s) == 0 || s[len(s)-1] != '\n' {
+	if len(l.buf) == 0 || l.buf[len(l.buf)-1] != '\n' {
 		l.buf = append(l.buf, '\n')
 	}
-	_, err := l.out.Write(l.buf)
+	if l.async != nil {
+		l.async.enqueue(w, l.buf, l.recordTime(e.Time))
+		return nil
+	}
+	if tw, ok := w.(timedWriter); ok {
+		_, err := tw.writeAt(l.buf, l.recordTime(e.Time))
+		return err
+	}
+	_, err := w.Write(l.buf)
 	return err
 }
 
 func (l *Logger) log(level Level, v ...interface{}) {
-	l.Output(level, "", v...)
+	l.output(0, level, "", v)
 }
 
 func (l *Logger) logf(level Level, format string, v ...interface{}) {
-	l.Output(level, format, v...)
+	l.output(0, level, format, v)
+}
+
+// Log logs at level, which is meant for levels added by RegisterLevel.
+func (l *Logger) Log(level Level, v ...interface{}) {
+	l.log(level, v...)
+}
+
+func (l *Logger) Logf(level Level, format string, v ...interface{}) {
+	l.logf(level, format, v...)
 }
 
 func (l *Logger) Trace(v ...interface{}) {
@@ -437,6 +469,22 @@ func (l *Logger) SetFlags(flag int) {
 	l.flag = flag
 }
 
+// Formatter returns the formatter set with SetFormatter or WithFormatter,
+// or nil when the layout is chosen by the Lmsgjson flag.
+func (l *Logger) Formatter() Formatter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.formatter
+}
+
+// SetFormatter sets the formatter for the logger. A nil formatter restores
+// the built-in layout chosen by the Lmsgjson flag.
+func (l *Logger) SetFormatter(formatter Formatter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.formatter = formatter
+}
+
 func (l *Logger) Prefix() string {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -485,6 +533,10 @@ func (l *Logger) SetCallDepth(calldepath int) {
 	l.callDepth = calldepath
 }
 
+// AutoCallDepth increases the call depth of l by one, the first time only.
+//
+// Deprecated: AutoCallDepth changes the call depth for every goroutine
+// logging through l. Use the Depth variants, such as InfoDepth, or Helper.
 func (l *Logger) AutoCallDepth() {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -524,10 +576,28 @@ func AddWriteCloser(writeClosers ...io.WriteCloser) {
 	glog.AddWriteCloser(writeClosers...)
 }
 
+// Flush waits until the records queued by the standard logger have been written.
+func Flush() error {
+	return glog.Flush()
+}
+
 func Close() error {
 	return glog.Close()
 }
 
+// WithFields returns a child of the standard logger that adds fields to every record.
+func WithFields(fields Fields) *Logger {
+	l := glog.WithFields(fields)
+	// The child is called directly, without the package-level wrapper frame.
+	l.callDepth--
+	return l
+}
+
+// WithField returns a child of the standard logger that adds key=value to every record.
+func WithField(key string, value interface{}) *Logger {
+	return WithFields(Fields{key: value})
+}
+
 // Flags returns the output flags for the standard logger.
 // The flag bits are Ldate, Ltime, and so on.
 func Flags() int {
@@ -540,6 +610,11 @@ func SetFlags(flag int) {
 	glog.SetFlags(flag)
 }
 
+// SetFormatter sets the formatter for the standard logger.
+func SetFormatter(formatter Formatter) {
+	glog.SetFormatter(formatter)
+}
+
 // Prefix returns the output prefix for the standard logger.
 func Prefix() string {
 	return glog.Prefix()
@@ -574,10 +649,16 @@ func SetCallDepth(calldepth int) {
 	glog.SetCallDepth(calldepth)
 }
 
+// AutoCallDepth increases the call depth of the standard logger by one.
+//
+// Deprecated: use the Depth variants, such as InfoDepth, or Helper.
 func AutoCallDepth() {
 	glog.AutoCallDepth()
 }
 
+// ResetCallDepth restores the call depth of the standard logger.
+//
+// Deprecated: use the Depth variants, such as InfoDepth, or Helper.
 func ResetCallDepth() {
 	glog.SetCallDepth(4)
 }
@@ -587,6 +668,14 @@ func Writer() io.Writer {
 	return glog.Writer()
 }
 
+func Log(level Level, v ...interface{}) {
+	glog.Log(level, v...)
+}
+
+func Logf(level Level, format string, v ...interface{}) {
+	glog.Logf(level, format, v...)
+}
+
 func Trace(v ...interface{}) {
 	glog.Trace(v...)
 }
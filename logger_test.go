@@ -2,10 +2,9 @@ package glog
 
 import (
 	"bytes"
+	"encoding/json"
 	"log"
 	"testing"
-
-	"github.com/sirupsen/logrus"
 )
 
 type callDepth struct {
@@ -31,6 +30,47 @@ func TestCallDepth(t *testing.T) {
 	}
 }
 
+func TestWithFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lmsglevel))
+	child := l.WithFields(Fields{"user": "cody", "id": 7}).WithField("msg", "a b")
+	child.Info("hello")
+	want := "[INFO] hello id=7 msg=\"a b\" user=cody\n"
+	if got := buf.String(); got != want {
+		t.Errorf("text fields: expected %q, got %q", want, got)
+	}
+
+	buf.Reset()
+	l.Info("parent")
+	if got, want := buf.String(), "[INFO] parent\n"; got != want {
+		t.Errorf("parent: expected %q, got %q", want, got)
+	}
+
+	buf.Reset()
+	l.SetOutput(&bytes.Buffer{})
+	child.Info("moved")
+	if buf.Len() != 0 {
+		t.Errorf("child did not follow parent output, wrote %q", buf.String())
+	}
+}
+
+func TestWithFieldsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lmsgjson|Lmsglevel))
+	l.WithField("id", 7).Info("hello")
+	var got struct {
+		Level   string
+		Message string
+		Fields  map[string]interface{}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("json fields: %v in %q", err, buf.String())
+	}
+	if got.Level != "INFO" || got.Message != "hello" || got.Fields["id"] != float64(7) {
+		t.Errorf("json fields: unexpected record %q", buf.String())
+	}
+}
+
 func BenchmarkStdLogPrintf(b *testing.B) {
 	const testString = "test"
 	var buf bytes.Buffer
@@ -111,43 +151,55 @@ func BenchmarkGLogInfoNoFlags(b *testing.B) {
 	}
 }
 
-func BenchmarkLogRusInfo(b *testing.B) {
+func BenchmarkGLogJsonInfoNoFlags(b *testing.B) {
 	const testString = "test"
 	var buf bytes.Buffer
-	logrus.SetOutput(&buf)
+	l := New(&buf, WithFlags(0))
 	for i := 0; i < b.N; i++ {
 		buf.Reset()
-		logrus.Info(testString)
+		l.Info(testString)
 	}
 }
 
-func BenchmarkGLogJsonInfoNoFlags(b *testing.B) {
+func BenchmarkGLogJsonInfo(b *testing.B) {
 	const testString = "test"
 	var buf bytes.Buffer
-	l := New(&buf, WithFlags(0))
+	l := New(&buf, WithFlags(LglogFlags|Lmsgjson))
 	for i := 0; i < b.N; i++ {
 		buf.Reset()
 		l.Info(testString)
 	}
 }
 
-func BenchmarkGLogJsonInfo(b *testing.B) {
+func BenchmarkStdLogPrintlnShortfile(b *testing.B) {
 	const testString = "test"
 	var buf bytes.Buffer
-	l := New(&buf, WithFlags(LglogFlags|Lmsgjson))
+	l := log.New(&buf, "", log.LstdFlags|log.Lshortfile)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		l.Println(testString)
+	}
+}
+
+func BenchmarkGLogInfoShortfile(b *testing.B) {
+	const testString = "test"
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(LstdFlags|Lshortfile))
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		buf.Reset()
 		l.Info(testString)
 	}
 }
 
-func BenchmarkLogRusJsonInfo(b *testing.B) {
+func BenchmarkGLogInfoFuncname(b *testing.B) {
 	const testString = "test"
 	var buf bytes.Buffer
-	logrus.SetFormatter(&logrus.JSONFormatter{})
-	logrus.SetOutput(&buf)
+	l := New(&buf, WithFlags(LstdFlags|Lshortfile|Lfuncname))
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		buf.Reset()
-		logrus.Info(testString)
+		l.Info(testString)
 	}
 }
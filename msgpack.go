@@ -0,0 +1,351 @@
+package glog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// The MessagePack encoding of the Fluentd forward protocol, limited to what
+// FluentWriter sends and reads. The append functions follow strconv: they
+// append an encoded value to b and return the extended buffer.
+
+// maxMsgpackLength bounds the strings, binaries, arrays and maps read from a
+// server, so that a corrupt length does not exhaust memory.
+const maxMsgpackLength = 1 << 24
+
+func appendMsgpackNil(b []byte) []byte {
+	return append(b, 0xc0)
+}
+
+func appendMsgpackBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMsgpackUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+}
+
+func appendMsgpackUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+}
+
+func appendMsgpackFloat(b []byte, v float64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v))
+}
+
+// appendMsgpackHeader writes the header of a value of family whose length
+// is n: fix is the fixed format for lengths below fixMax, and the 8, 16 and
+// 32 bit formats follow, 8 bit being skipped when format8 is 0.
+func appendMsgpackHeader(b []byte, n int, fix byte, fixMax int, format8, format16 byte) []byte {
+	switch {
+	case n < fixMax:
+		return append(b, fix|byte(n))
+	case format8 != 0 && n <= math.MaxUint8:
+		return append(b, format8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, format16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, format16+1), uint32(n))
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	b = appendMsgpackHeader(b, len(s), 0xa0, 32, 0xd9, 0xda)
+	return append(b, s...)
+}
+
+func appendMsgpackBin(b []byte, p []byte) []byte {
+	switch n := len(p); {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, p...)
+}
+
+func appendMsgpackArray(b []byte, n int) []byte {
+	return appendMsgpackHeader(b, n, 0x90, 16, 0, 0xdc)
+}
+
+func appendMsgpackMap(b []byte, n int) []byte {
+	return appendMsgpackHeader(b, n, 0x80, 16, 0, 0xde)
+}
+
+// appendEventTime writes t as the EventTime extension of Fluentd: type 0
+// holding the seconds and nanoseconds as big endian uint32.
+func appendEventTime(b []byte, t time.Time) []byte {
+	b = append(b, 0xd7, 0x00)
+	b = binary.BigEndian.AppendUint32(b, uint32(t.Unix()))
+	return binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+}
+
+// appendMsgpackValue writes v, a field value: numbers, strings, booleans,
+// byte slices, maps with string keys and slices keep their type, times are
+// written in RFC 3339 and other values as formatted by fmt.Sprint.
+func appendMsgpackValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return appendMsgpackNil(b)
+	case bool:
+		return appendMsgpackBool(b, v)
+	case int:
+		return appendMsgpackInt(b, int64(v))
+	case int8:
+		return appendMsgpackInt(b, int64(v))
+	case int16:
+		return appendMsgpackInt(b, int64(v))
+	case int32:
+		return appendMsgpackInt(b, int64(v))
+	case int64:
+		return appendMsgpackInt(b, v)
+	case uint:
+		return appendMsgpackUint(b, uint64(v))
+	case uint8:
+		return appendMsgpackUint(b, uint64(v))
+	case uint16:
+		return appendMsgpackUint(b, uint64(v))
+	case uint32:
+		return appendMsgpackUint(b, uint64(v))
+	case uint64:
+		return appendMsgpackUint(b, v)
+	case float32:
+		return appendMsgpackFloat(b, float64(v))
+	case float64:
+		return appendMsgpackFloat(b, v)
+	case string:
+		return appendMsgpackString(b, v)
+	case []byte:
+		return appendMsgpackBin(b, v)
+	case time.Time:
+		return appendMsgpackString(b, v.Format(time.RFC3339Nano))
+	case Fields:
+		return appendMsgpackFields(b, v)
+	case map[string]interface{}:
+		return appendMsgpackFields(b, v)
+	case []interface{}:
+		b = appendMsgpackArray(b, len(v))
+		for _, e := range v {
+			b = appendMsgpackValue(b, e)
+		}
+		return b
+	case []string:
+		b = appendMsgpackArray(b, len(v))
+		for _, s := range v {
+			b = appendMsgpackString(b, s)
+		}
+		return b
+	}
+	return appendMsgpackString(b, fmt.Sprint(v))
+}
+
+func appendMsgpackFields(b []byte, fields map[string]interface{}) []byte {
+	b = appendMsgpackMap(b, len(fields))
+	for k, v := range fields {
+		b = appendMsgpackString(b, k)
+		b = appendMsgpackValue(b, v)
+	}
+	return b
+}
+
+// msgpackExt is an extension value read by readMsgpack.
+type msgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+// readMsgpack reads one value: nil, bool, int64, uint64, float64, string,
+// []byte, []interface{}, map[string]interface{} or msgpackExt. Map keys that
+// are not strings are formatted by fmt.Sprint.
+func readMsgpack(r *bufio.Reader) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return readMsgpackMap(r, int(c&0x0f))
+	case c&0xf0 == 0x90:
+		return readMsgpackArray(r, int(c&0x0f))
+	case c&0xe0 == 0xa0:
+		p, err := readMsgpackBytes(r, int(c&0x1f))
+		return string(p), err
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readMsgpackLength(r, c-0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackBytes(r, n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := readMsgpackLength(r, c-0xc7)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackExt(r, n)
+	case 0xca:
+		p, err := readMsgpackBytes(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(p))), nil
+	case 0xcb:
+		p, err := readMsgpackBytes(r, 8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(p)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		p, err := readMsgpackBytes(r, 1<<(c-0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return readBigEndian(p), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		p, err := readMsgpackBytes(r, 1<<(c-0xd0))
+		if err != nil {
+			return nil, err
+		}
+		shift := 64 - 8*uint(len(p))
+		return int64(readBigEndian(p)<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return readMsgpackExt(r, 1<<(c-0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := readMsgpackLength(r, c-0xd9)
+		if err != nil {
+			return nil, err
+		}
+		p, err := readMsgpackBytes(r, n)
+		return string(p), err
+	case 0xdc, 0xdd:
+		n, err := readMsgpackLength(r, c-0xdc+1)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackArray(r, n)
+	case 0xde, 0xdf:
+		n, err := readMsgpackLength(r, c-0xde+1)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, n)
+	}
+	return nil, fmt.Errorf("msgpack: invalid format 0x%02x", c)
+}
+
+// readMsgpackLength reads a big endian length of 1, 2 or 4 bytes for a size
+// of 0, 1 or 2.
+func readMsgpackLength(r *bufio.Reader, size byte) (int, error) {
+	p, err := readMsgpackBytes(r, 1<<size)
+	if err != nil {
+		return 0, err
+	}
+	n := readBigEndian(p)
+	if n > maxMsgpackLength {
+		return 0, fmt.Errorf("msgpack: length %d too large", n)
+	}
+	return int(n), nil
+}
+
+func readMsgpackBytes(r *bufio.Reader, n int) ([]byte, error) {
+	p := make([]byte, n)
+	if _, err := io.ReadFull(r, p); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+func readMsgpackExt(r *bufio.Reader, n int) (interface{}, error) {
+	p, err := readMsgpackBytes(r, n+1)
+	if err != nil {
+		return nil, err
+	}
+	return msgpackExt{Type: int8(p[0]), Data: p[1:]}, nil
+}
+
+func readMsgpackArray(r *bufio.Reader, n int) (interface{}, error) {
+	a := make([]interface{}, 0, min(n, 64))
+	for i := 0; i < n; i++ {
+		v, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func readMsgpackMap(r *bufio.Reader, n int) (interface{}, error) {
+	m := make(map[string]interface{}, min(n, 64))
+	for i := 0; i < n; i++ {
+		k, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		switch k := k.(type) {
+		case string:
+			m[k] = v
+		case []byte:
+			m[string(k)] = v
+		default:
+			m[fmt.Sprint(k)] = v
+		}
+	}
+	return m, nil
+}
+
+func readBigEndian(p []byte) uint64 {
+	var n uint64
+	for _, c := range p {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
@@ -0,0 +1,104 @@
+package glog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMsgpackEncoding(t *testing.T) {
+	for _, test := range []struct {
+		v    interface{}
+		want string
+	}{
+		{nil, "c0"},
+		{true, "c3"},
+		{0, "00"},
+		{127, "7f"},
+		{128, "cc80"},
+		{-1, "ff"},
+		{-32, "e0"},
+		{-33, "d0df"},
+		{-129, "d1ff7f"},
+		{1 << 16, "ce00010000"},
+		{uint64(1) << 32, "cf0000000100000000"},
+		{int64(math.MinInt64), "d38000000000000000"},
+		{1.5, "cb3ff8000000000000"},
+		{"", "a0"},
+		{"abc", "a3616263"},
+		{strings.Repeat("x", 32), "d920" + strings.Repeat("78", 32)},
+		{[]byte{1, 2}, "c4020102"},
+		{[]interface{}{1, "a"}, "9201a161"},
+		{Fields{"k": 1}, "81a16b01"},
+		{struct{}{}, "a27b7d"},
+	} {
+		if got := hex.EncodeToString(appendMsgpackValue(nil, test.v)); got != test.want {
+			t.Errorf("msgpack %#v: expected %s, got %s", test.v, test.want, got)
+		}
+	}
+	tm := time.Unix(1700000000, 123).UTC()
+	if got := hex.EncodeToString(appendEventTime(nil, tm)); got != "d700"+"6553f100"+"0000007b" {
+		t.Errorf("event time: unexpected %s", got)
+	}
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	long := strings.Repeat("y", 70000)
+	in := map[string]interface{}{
+		"nil":    nil,
+		"bool":   false,
+		"int":    -100000,
+		"uint":   uint64(math.MaxUint64),
+		"float":  2.25,
+		"string": long,
+		"bin":    bytes.Repeat([]byte{7}, 300),
+		"array":  []interface{}{1, []interface{}{"nested"}},
+		"map":    map[string]interface{}{"a": 1},
+		"list":   make([]interface{}, 20),
+	}
+	want := map[string]interface{}{
+		"nil":    nil,
+		"bool":   false,
+		"int":    int64(-100000),
+		"uint":   uint64(math.MaxUint64),
+		"float":  2.25,
+		"string": long,
+		"bin":    bytes.Repeat([]byte{7}, 300),
+		"array":  []interface{}{int64(1), []interface{}{"nested"}},
+		"map":    map[string]interface{}{"a": int64(1)},
+		"list":   make([]interface{}, 20),
+	}
+	p := appendMsgpackValue(nil, in)
+	p = appendEventTime(p, time.Unix(1, 2))
+	r := bufio.NewReader(bytes.NewReader(p))
+	got, err := readMsgpack(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range want {
+		if g := got.(map[string]interface{})[k]; !reflect.DeepEqual(g, v) {
+			t.Errorf("msgpack round trip of %s: expected %v, got %v", k, v, g)
+		}
+	}
+	ext, err := readMsgpack(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (msgpackExt{0, []byte{0, 0, 0, 1, 0, 0, 0, 2}}); !reflect.DeepEqual(ext, want) {
+		t.Errorf("msgpack ext: expected %v, got %v", want, ext)
+	}
+}
+
+func TestMsgpackInvalid(t *testing.T) {
+	for _, p := range []string{"", "c1", "a3616", "dbffffffff", "92c0"} {
+		b, _ := hex.DecodeString(p)
+		if v, err := readMsgpack(bufio.NewReader(bytes.NewReader(b))); err == nil {
+			t.Errorf("msgpack % x: expected an error, got %v", b, v)
+		}
+	}
+}
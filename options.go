@@ -3,6 +3,7 @@ package glog
 import (
 	"io"
 	"os"
+	"time"
 )
 
 type Option func(*Logger)
@@ -14,21 +15,29 @@ func WithFile(name string, flag int, perm os.FileMode) Option {
 			panic(err)
 		}
 		l.closers = append(l.closers, f)
-		l.out = io.MultiWriter(l.out, f)
+		l.out = io.MultiWriter(l.plainOutLocked(), f)
 	}
 }
 
 func WithMultiWriter(writers ...io.Writer) Option {
 	return func(l *Logger) {
-		writers = append(writers, l.out)
-		l.out = io.MultiWriter(writers...)
+		var plain []io.Writer
+		for _, w := range writers {
+			if keptApart(w) {
+				l.addLocked(w)
+			} else {
+				plain = append(plain, w)
+			}
+		}
+		plain = append(plain, l.plainOutLocked())
+		l.out = io.MultiWriter(plain...)
 	}
 }
 
 func WithWriteCloser(writeCloser io.WriteCloser) Option {
 	return func(l *Logger) {
 		l.closers = append(l.closers, writeCloser)
-		l.out = io.MultiWriter(l.out, writeCloser)
+		l.addLocked(writeCloser)
 	}
 }
 
@@ -36,17 +45,90 @@ func WithMultiWriteCloser(writeClosers ...io.WriteCloser) Option {
 	return func(l *Logger) {
 		for _, writeCloser := range writeClosers {
 			l.closers = append(l.closers, writeCloser)
-			l.out = io.MultiWriter(l.out, writeCloser)
+			l.addLocked(writeCloser)
 		}
 	}
 }
 
+// WithAsync formats records on the calling goroutine and writes them on a
+// background one through a queue of queueSize records. policy decides what
+// happens when the queue is full. Flush and Close drain the queue.
+func WithAsync(queueSize int, policy OverflowPolicy) Option {
+	return func(l *Logger) {
+		l.async = newAsyncWriter(queueSize, policy)
+	}
+}
+
+// WithHook registers hook for the levels it declares.
+func WithHook(hook Hook) Option {
+	return func(l *Logger) {
+		l.hooks.add(hook)
+	}
+}
+
+// WithLeveledOutput adds w as an output receiving only the records at level
+// or above, laid out by formatter, or by the formatter of the logger if nil.
+func WithLeveledOutput(w io.Writer, level Level, formatter Formatter) Option {
+	return func(l *Logger) {
+		l.sinks = append(l.sinks, &sink{w: w, level: level, formatter: formatter})
+	}
+}
+
+// WithLeveledWriteCloser is like WithLeveledOutput and also closes
+// writeCloser when the logger is closed.
+func WithLeveledWriteCloser(writeCloser io.WriteCloser, level Level, formatter Formatter) Option {
+	return func(l *Logger) {
+		l.closers = append(l.closers, writeCloser)
+		l.sinks = append(l.sinks, &sink{w: writeCloser, level: level, formatter: formatter})
+	}
+}
+
 func WithFlags(flag int) Option {
 	return func(l *Logger) {
 		l.flag = flag
 	}
 }
 
+// WithFormatter lays out records with formatter instead of the built-in
+// layout chosen by the Lmsgjson flag.
+func WithFormatter(formatter Formatter) Option {
+	return func(l *Logger) {
+		l.formatter = formatter
+	}
+}
+
+// WithColor overrides the color of level, see SetColor.
+func WithColor(level Level, sgr string) Option {
+	return func(l *Logger) {
+		if l.palette == nil {
+			l.palette = make(map[Level]string)
+		}
+		l.palette[level] = sgr
+	}
+}
+
+// WithTimeFormat sets the time layout, see SetTimeFormat.
+func WithTimeFormat(layout string) Option {
+	return func(l *Logger) {
+		l.timeFormat = layout
+	}
+}
+
+// WithLocation sets the time location, see SetLocation.
+func WithLocation(loc *time.Location) Option {
+	return func(l *Logger) {
+		l.location = loc
+	}
+}
+
+// WithStacktrace adds the stack of the caller to records at or above level,
+// see SetStacktrace.
+func WithStacktrace(level Level) Option {
+	return func(l *Logger) {
+		l.stacktrace, l.stackOn = level, true
+	}
+}
+
 func WithPrefix(prefix string) Option {
 	return func(l *Logger) {
 		l.prefix = prefix
@@ -65,12 +147,31 @@ func WithLevelLength(levelLength uint8) Option {
 	}
 }
 
+func WithVerbosity(level int) Option {
+	return func(l *Logger) {
+		l.SetVerbosity(level)
+	}
+}
+
+// WithVModule sets per-file verbosity overrides, see SetVModule. It panics
+// if spec is invalid.
+func WithVModule(spec string) Option {
+	return func(l *Logger) {
+		if err := l.SetVModule(spec); err != nil {
+			panic(err)
+		}
+	}
+}
+
 func WithCallDepth(calldepth int) Option {
 	return func(l *Logger) {
 		l.callDepth = calldepth
 	}
 }
 
+// WithAutoCallDepth increases the call depth of the logger by one.
+//
+// Deprecated: use the Depth variants, such as InfoDepth, or Helper.
 func WithAutoCallDepth() Option {
 	return func(l *Logger) {
 		l.AutoCallDepth()
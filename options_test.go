@@ -69,3 +69,11 @@ func TestWithAutoCallDepth(t *testing.T) {
 		t.Errorf("autoCallDepth 5: expected %d, got %d", want, got)
 	}
 }
+
+func TestWithFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFormatter(JSONFormatter{}))
+	if _, ok := l.Formatter().(JSONFormatter); !ok {
+		t.Errorf("formatter: expected JSONFormatter, got %T", l.Formatter())
+	}
+}
@@ -0,0 +1,452 @@
+package glog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the layout of the suffix given to backups by
+// TimestampBackups.
+const backupTimeFormat = "20060102-150405.000"
+
+// RotateOption configures a RotatingFile or a TimeRotatingFile.
+type RotateOption func(*rotateOptions)
+
+type rotateOptions struct {
+	perm       os.FileMode
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	timestamp  bool
+	utc        bool
+	symlink    string
+	compress   Compressor
+	now        func() time.Time // nil for time.Now
+}
+
+// extension returns the extension of compressed backups, if any.
+func (o *rotateOptions) extension() string {
+	if o.compress == nil {
+		return ""
+	}
+	return o.compress.Extension()
+}
+
+func (o *rotateOptions) clock() time.Time {
+	now := time.Now()
+	if o.now != nil {
+		now = o.now()
+	}
+	if o.utc {
+		return now.UTC()
+	}
+	return now.Local()
+}
+
+// recordClock returns the time placing a record stamped t: t, or the time
+// set with Clock in the location of t, in UTC when UTC is set.
+func (o *rotateOptions) recordClock(t time.Time) time.Time {
+	if o.now != nil {
+		t = o.now().In(t.Location())
+	}
+	if o.utc {
+		return t.UTC()
+	}
+	return t
+}
+
+// FilePerm sets the permission bits of newly created log files. Default 0644.
+func FilePerm(perm os.FileMode) RotateOption {
+	return func(o *rotateOptions) {
+		o.perm = perm
+	}
+}
+
+// MaxSize rotates the file before a write would grow it past size bytes.
+// Zero, the default, never rotates on size.
+func MaxSize(size int64) RotateOption {
+	return func(o *rotateOptions) {
+		o.maxSize = size
+	}
+}
+
+// MaxBackups keeps at most n rotated files, removing the oldest ones.
+// Zero, the default, keeps them all.
+func MaxBackups(n int) RotateOption {
+	return func(o *rotateOptions) {
+		o.maxBackups = n
+	}
+}
+
+// MaxAge removes rotated files last modified more than d ago.
+// Zero, the default, keeps them regardless of age.
+func MaxAge(d time.Duration) RotateOption {
+	return func(o *rotateOptions) {
+		o.maxAge = d
+	}
+}
+
+// TimestampBackups names rotated files name.20060102-150405.000 after the
+// time of rotation instead of numbering them name.1, name.2 and so on.
+func TimestampBackups() RotateOption {
+	return func(o *rotateOptions) {
+		o.timestamp = true
+	}
+}
+
+// UTC names and rotates files by the UTC clock rather than the local one.
+// A TimeRotatingFile written by a logger follows its LUTC flag and location
+// without it.
+func UTC() RotateOption {
+	return func(o *rotateOptions) {
+		o.utc = true
+	}
+}
+
+// Clock replaces time.Now as the source of the current time, so that
+// rotation can be driven deterministically. It also replaces the time of
+// the records written by a logger to a TimeRotatingFile.
+func Clock(now func() time.Time) RotateOption {
+	return func(o *rotateOptions) {
+		o.now = now
+	}
+}
+
+// Symlink maintains a symbolic link named link pointing at the file being
+// written by a TimeRotatingFile.
+func Symlink(link string) RotateOption {
+	return func(o *rotateOptions) {
+		o.symlink = link
+	}
+}
+
+// RotatingFile is an io.WriteCloser appending to a named file that is
+// rotated once it reaches a maximum size. It is safe for concurrent use
+// and can be handed to SetWriteCloser, AddWriteCloser or WithWriteCloser,
+// in which case it is closed by (*Logger).Close.
+//
+// By default rotated files are numbered: name.1 is the most recent backup,
+// name.2 the one before it, and so on.
+type RotatingFile struct {
+	mu     sync.Mutex
+	name   string
+	opts   rotateOptions
+	file   *os.File
+	size   int64
+	limit  int64 // size past which the file is rotated
+	closed bool
+	worker *backgroundWorker
+	errs   chan error
+}
+
+// NewRotatingFile opens name for appending, creating it if needed,
+// and returns a RotatingFile writing to it.
+func NewRotatingFile(name string, options ...RotateOption) (*RotatingFile, error) {
+	r := &RotatingFile{
+		name: name,
+		opts: rotateOptions{perm: 0644},
+	}
+	for _, option := range options {
+		option(&r.opts)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	if r.opts.compress != nil {
+		r.worker = newBackgroundWorker()
+		r.errs = r.worker.errs
+	} else {
+		r.errs = make(chan error, errorBuffer)
+	}
+	return r, nil
+}
+
+// Name returns the name of the file being written.
+func (r *RotatingFile) Name() string {
+	return r.name
+}
+
+// Errors returns a channel reporting the failures of the rotations made by
+// Write and of background compression and cleanup. It is closed by Close.
+// When it is not drained the oldest errors are discarded.
+func (r *RotatingFile) Errors() <-chan error {
+	return r.errs
+}
+
+// Write appends p to the file, rotating it first if p would grow it past
+// the maximum size. A single write is never split across files. When the
+// rotation fails but a file is still open, p is written to it, the error
+// is reported on Errors and the rotation is tried again once the file has
+// grown by another MaxSize.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.opts.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.limit {
+		if err := r.rotate(); err != nil {
+			if r.file == nil {
+				return 0, err
+			}
+			r.limit = r.size + r.opts.maxSize
+			reportError(r.errs, err)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file, renames it to a backup and opens a new one.
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.rotate()
+}
+
+// Close closes the underlying file and waits for pending compressions.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	r.closed = true
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	if r.worker != nil {
+		r.worker.close()
+	} else {
+		close(r.errs)
+	}
+	return err
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, r.opts.perm)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = fi.Size()
+	r.limit = r.opts.maxSize
+	return nil
+}
+
+// rotate must be called with r.mu held. If the rename or the cleanup fails
+// the file is still open, the current one after a failed rename, so that
+// writes keep going to it.
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+	if r.worker != nil {
+		return r.stage()
+	}
+	renameErr := r.backup()
+	if err := r.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	return r.opts.cleanup(r.name)
+}
+
+// stage moves the full file out of the way under a unique name and leaves
+// numbering, compression and cleanup to the background worker, which is
+// the only one to touch backups once compression is on. The staged name
+// is never one of a backup, so that cleanup does not count files still
+// waiting for compression.
+func (r *RotatingFile) stage() error {
+	now := r.opts.clock()
+	staged := r.name + ".rotated-" + strconv.FormatInt(now.UnixNano(), 10)
+	renameErr := os.Rename(r.name, staged)
+	if err := r.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	r.worker.queue(func() error {
+		return r.archive(staged, now)
+	})
+	return nil
+}
+
+// archive compresses a staged file, rotated at t, to its backup name. It
+// runs on the background worker.
+func (r *RotatingFile) archive(staged string, t time.Time) error {
+	ext := r.opts.compress.Extension()
+	dst := r.name + "." + t.Format(backupTimeFormat) + ext
+	if !r.opts.timestamp {
+		if err := shiftBackups(r.name, ext); err != nil {
+			return err
+		}
+		dst = numberedName(r.name, 1) + ext
+	}
+	if err := compressFile(r.opts.compress, staged, dst); err != nil {
+		return err
+	}
+	return r.opts.cleanup(r.name)
+}
+
+func (r *RotatingFile) backup() error {
+	if r.opts.timestamp {
+		backup := r.name + "." + r.opts.clock().Format(backupTimeFormat)
+		return os.Rename(r.name, backup)
+	}
+	if err := shiftBackups(r.name, ""); err != nil {
+		return err
+	}
+	return os.Rename(r.name, numberedName(r.name, 1))
+}
+
+// shiftBackups renames name.N and name.N+ext to name.N+1 and name.N+1+ext,
+// from the highest number down so that no backup is overwritten before it
+// has been moved.
+func shiftBackups(name, ext string) error {
+	backups := listBackups(globEscape(name)+".*", func(m string) bool {
+		_, ok := backupNumber(name, m, ext)
+		return ok
+	})
+	sortNumbered(name, ext, backups)
+	for i := len(backups) - 1; i >= 0; i-- {
+		m := backups[i].name
+		n, _ := backupNumber(name, m, ext)
+		suffix := ""
+		if ext != "" && strings.HasSuffix(m, ext) {
+			suffix = ext
+		}
+		if err := os.Rename(m, numberedName(name, n+1)+suffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func numberedName(name string, n int) string {
+	return name + "." + strconv.Itoa(n)
+}
+
+// backupNumber returns N for a backup m named name.N or name.N+ext.
+func backupNumber(name, m, ext string) (int, bool) {
+	suffix := strings.TrimPrefix(m, name+".")
+	if ext != "" {
+		suffix = strings.TrimSuffix(suffix, ext)
+	}
+	n, err := strconv.Atoi(suffix)
+	return n, err == nil && n > 0
+}
+
+// sortNumbered orders numbered backups by their number, newest first.
+func sortNumbered(name, ext string, backups []backupFile) {
+	sort.SliceStable(backups, func(i, j int) bool {
+		ni, _ := backupNumber(name, backups[i].name, ext)
+		nj, _ := backupNumber(name, backups[j].name, ext)
+		return ni < nj
+	})
+}
+
+// globEscape quotes the glob metacharacters of name.
+func globEscape(name string) string {
+	var b strings.Builder
+	for _, c := range name {
+		switch c {
+		case '*', '?', '[', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// backupFile is a rotated file found on disk.
+type backupFile struct {
+	name    string
+	modTime time.Time
+}
+
+// listBackups returns the regular files matching glob that are also
+// accepted by match, newest first. Symbolic links, such as the one set with
+// Symlink, are not followed.
+func listBackups(glob string, match func(name string) bool) []backupFile {
+	matches, _ := filepath.Glob(glob)
+	var backups []backupFile
+	for _, m := range matches {
+		if !match(m) {
+			continue
+		}
+		fi, err := os.Lstat(m)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		backups = append(backups, backupFile{name: m, modTime: fi.ModTime()})
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		if backups[i].modTime.Equal(backups[j].modTime) {
+			return backups[i].name > backups[j].name
+		}
+		return backups[i].modTime.After(backups[j].modTime)
+	})
+	return backups
+}
+
+// cleanup removes the backups of name exceeding MaxBackups or MaxAge.
+func (o *rotateOptions) cleanup(name string) error {
+	if o.maxBackups <= 0 && o.maxAge <= 0 {
+		return nil
+	}
+	ext := o.extension()
+	backups := listBackups(globEscape(name)+".*", func(m string) bool {
+		if o.timestamp {
+			suffix := strings.TrimSuffix(strings.TrimPrefix(m, name+"."), ext)
+			_, err := time.Parse(backupTimeFormat, suffix)
+			return err == nil
+		}
+		_, ok := backupNumber(name, m, ext)
+		return ok
+	})
+	if !o.timestamp {
+		// Numbered backups are ordered by their number, not their mtime.
+		sortNumbered(name, ext, backups)
+	}
+	return o.removeExpired(backups)
+}
+
+// removeExpired removes the backups, ordered newest first, that are beyond
+// MaxBackups or older than MaxAge.
+func (o *rotateOptions) removeExpired(backups []backupFile) error {
+	var errs []error
+	cutoff := o.clock().Add(-o.maxAge)
+	for i, b := range backups {
+		if (o.maxBackups > 0 && i >= o.maxBackups) || (o.maxAge > 0 && b.modTime.Before(cutoff)) {
+			if err := os.Remove(b.name); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%+v", errs)
+	}
+	return nil
+}
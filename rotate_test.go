@@ -0,0 +1,188 @@
+package glog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// tempDir creates a directory for the test and returns it along with a
+// function removing it.
+func tempDir(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "glog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	name := filepath.Join(dir, "app.log")
+	r, err := NewRotatingFile(name, MaxSize(10), MaxBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(Discard, WithFlags(0), WithWriteCloser(r))
+	for _, s := range []string{"one", "two", "three", "four", "five", "six"} {
+		l.Info(s)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		name:        "six\n",
+		name + ".1": "four\nfive\n",
+		name + ".2": "three\n",
+	}
+	for file, content := range want {
+		if got := readFile(t, file); got != content {
+			t.Errorf("%s: expected %q, got %q", filepath.Base(file), content, got)
+		}
+	}
+	if _, err := os.Stat(name + ".3"); !os.IsNotExist(err) {
+		t.Errorf("backup .3 should have been removed, stat error: %v", err)
+	}
+	if _, err := r.Write([]byte("closed")); err != os.ErrClosed {
+		t.Errorf("write after close: expected %v, got %v", os.ErrClosed, err)
+	}
+}
+
+func TestRotatingFileTimestamp(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	name := filepath.Join(dir, "app.log")
+	now := time.Date(2020, 4, 27, 23, 15, 24, 0, time.UTC)
+	r, err := NewRotatingFile(name, TimestampBackups(), UTC(), Clock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Write([]byte("before\n"))
+	if err := r.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("after\n"))
+	if got := readFile(t, name+".20200427-231524.000"); got != "before\n" {
+		t.Errorf("timestamped backup: expected %q, got %q", "before\n", got)
+	}
+	if got := readFile(t, name); got != "after\n" {
+		t.Errorf("current file: expected %q, got %q", "after\n", got)
+	}
+}
+
+func TestRotatingFileConcurrent(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	name := filepath.Join(dir, "app.log")
+	r, err := NewRotatingFile(name, MaxSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				r.Write([]byte("0123456789\n"))
+			}
+		}()
+	}
+	wg.Wait()
+	r.Close()
+	files, _ := filepath.Glob(name + "*")
+	var total int
+	for _, f := range files {
+		total += strings.Count(readFile(t, f), "0123456789\n")
+	}
+	if total != 400 {
+		t.Errorf("concurrent writes: expected 400 lines, got %d", total)
+	}
+}
+
+func TestRotatingFileRenameFailure(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	name := filepath.Join(dir, "app.log")
+	// A non-empty directory in the way of the first backup.
+	if err := os.MkdirAll(filepath.Join(name+".1", "busy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRotatingFile(name, MaxSize(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := &nopCloser{}
+	l := New(Discard, WithFlags(0), WithWriteCloser(r), WithWriteCloser(after))
+	// The rotation fails on "three" and is tried again once the file has
+	// grown by another 10 bytes, on "fifth".
+	for _, s := range []string{"one", "two", "three", "4", "fifth"} {
+		l.Info(s)
+	}
+	if n, err := r.Write([]byte("6\n")); n != 2 || err != nil {
+		t.Errorf("write: expected 2 bytes and no error, got %d and %v", n, err)
+	}
+	if got, want := after.String(), "one\ntwo\nthree\n4\nfifth\n"; got != want {
+		t.Errorf("output after the file: expected %q, got %q", want, got)
+	}
+	if got, want := readFile(t, name), "one\ntwo\nthree\n4\nfifth\n6\n"; got != want {
+		t.Errorf("current file: expected %q, got %q", want, got)
+	}
+	l.Close()
+	var errs []error
+	for err := range r.Errors() {
+		errs = append(errs, err)
+	}
+	if len(errs) != 2 {
+		t.Errorf("errors: expected 2 failed rotations, got %v", errs)
+	}
+}
+
+func TestRotatingFileMaxAge(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	name := filepath.Join(dir, "app.log")
+	now := time.Now()
+	r, err := NewRotatingFile(name, MaxAge(time.Hour), Clock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, s := range []string{"old\n", "recent\n"} {
+		r.Write([]byte(s))
+		if err := r.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The older backup, now name.2, was last modified two hours ago.
+	old := now.Add(-2 * time.Hour)
+	if err := os.Chtimes(name+".2", old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, name+".2"); got != "recent\n" {
+		t.Errorf("recent backup: expected %q, got %q", "recent\n", got)
+	}
+	if _, err := os.Stat(name + ".3"); !os.IsNotExist(err) {
+		t.Errorf("backup older than MaxAge should have been removed, stat error: %v", err)
+	}
+}
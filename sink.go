@@ -0,0 +1,167 @@
+package glog
+
+import (
+	"io"
+	"time"
+)
+
+// An EntryWriter is an output that takes records as Entries instead of
+// formatted bytes, for destinations that keep the level, caller and fields
+// apart from the message. Outputs implementing it are given the Entry
+// wherever they are registered: SetOutput, AddOutput, AddWriteCloser,
+// AddLeveledOutput and their options alike. Their formatter is not used.
+//
+// WriteEntry must not keep a reference to e after it returns.
+type EntryWriter interface {
+	io.Writer
+	WriteEntry(e *Entry) error
+}
+
+// sink is an output with its own minimum level and formatter, added with
+// AddLeveledOutput or AddLeveledWriteCloser, or an EntryWriter added with
+// AddOutput or AddWriteCloser, which follows the level of the logger.
+type sink struct {
+	w         io.Writer
+	level     Level
+	follow    bool      // use the level of the logger instead of level
+	formatter Formatter // nil for the formatter of the logger
+}
+
+func (s *sink) enabled(l *Logger, level Level) bool {
+	if s.follow {
+		return level.atLeast(l.level)
+	}
+	return level.atLeast(s.level)
+}
+
+// enabledLocked reports whether a record at level reaches any output:
+// either those following the level of the logger or a leveled one.
+func (l *Logger) enabledLocked(level Level) bool {
+	if level.atLeast(l.level) {
+		return true
+	}
+	for _, s := range l.sinks {
+		if s.enabled(l, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled reports whether a record at level would be written to any output.
+func (l *Logger) Enabled(level Level) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enabledLocked(level)
+}
+
+// callerWriter is implemented by the EntryWriters of this package that
+// report the caller of records even when the flags of the logger do not ask
+// for it.
+type callerWriter interface {
+	wantsCaller() bool
+}
+
+// wantsCallerLocked reports whether an output wants the caller of records
+// regardless of the flags.
+func (l *Logger) wantsCallerLocked() bool {
+	if w, ok := l.out.(callerWriter); ok && w.wantsCaller() {
+		return true
+	}
+	for _, s := range l.sinks {
+		if w, ok := s.w.(callerWriter); ok && w.wantsCaller() {
+			return true
+		}
+	}
+	return false
+}
+
+// timedWriter is implemented by the writers of this package that choose
+// where a record goes by its time. The logger passes the time in the
+// location it shows records in, UTC with LUTC.
+type timedWriter interface {
+	io.Writer
+	writeAt(p []byte, t time.Time) (int, error)
+}
+
+// keptApart reports whether w must be kept out of io.MultiWriter, which
+// would reduce an EntryWriter or a timedWriter to plain writes.
+func keptApart(w io.Writer) bool {
+	switch w.(type) {
+	case EntryWriter, timedWriter:
+		return true
+	}
+	return false
+}
+
+// addLocked adds w to the outputs following the level of the logger.
+// An EntryWriter or a timedWriter is kept apart so that io.MultiWriter does
+// not reduce it to plain writes.
+func (l *Logger) addLocked(w io.Writer) {
+	if keptApart(w) {
+		l.sinks = append(l.sinks, &sink{w: w, follow: true})
+		return
+	}
+	l.out = io.MultiWriter(l.plainOutLocked(), w)
+}
+
+// dropFollowingLocked removes the sinks following the level of the logger,
+// which SetOutput and its variants replace along with l.out, and closes
+// those that were added as closers. Leveled sinks are kept.
+func (l *Logger) dropFollowingLocked() {
+	var sinks []*sink
+	for _, s := range l.sinks {
+		if !s.follow {
+			sinks = append(sinks, s)
+			continue
+		}
+		for i, c := range l.closers {
+			if interface{}(c) == interface{}(s.w) {
+				c.Close()
+				l.closers = append(l.closers[:i], l.closers[i+1:]...)
+				break
+			}
+		}
+	}
+	l.sinks = sinks
+}
+
+// plainOutLocked returns l.out for wrapping in io.MultiWriter, first moving
+// it to the sinks if it must be kept apart.
+func (l *Logger) plainOutLocked() io.Writer {
+	if keptApart(l.out) {
+		l.sinks = append(l.sinks, &sink{w: l.out, follow: true})
+		l.out = Discard
+	}
+	return l.out
+}
+
+// AddLeveledOutput adds w as an output receiving only the records at level
+// or above, laid out by formatter, or by the formatter of the logger if nil.
+// Unlike the outputs set with SetOutput or AddOutput, it does not follow
+// the level of the logger.
+func (l *Logger) AddLeveledOutput(w io.Writer, level Level, formatter Formatter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sinks = append(l.sinks, &sink{w: w, level: level, formatter: formatter})
+}
+
+// AddLeveledWriteCloser is like AddLeveledOutput and also closes
+// writeCloser when the logger is closed.
+func (l *Logger) AddLeveledWriteCloser(writeCloser io.WriteCloser, level Level, formatter Formatter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closers = append(l.closers, writeCloser)
+	l.sinks = append(l.sinks, &sink{w: writeCloser, level: level, formatter: formatter})
+}
+
+// AddLeveledOutput adds a leveled output to the standard logger.
+func AddLeveledOutput(w io.Writer, level Level, formatter Formatter) {
+	glog.AddLeveledOutput(w, level, formatter)
+}
+
+// AddLeveledWriteCloser adds a leveled output to the standard logger,
+// closed by Close.
+func AddLeveledWriteCloser(writeCloser io.WriteCloser, level Level, formatter Formatter) {
+	glog.AddLeveledWriteCloser(writeCloser, level, formatter)
+}
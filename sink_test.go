@@ -0,0 +1,112 @@
+package glog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLeveledOutput(t *testing.T) {
+	var console, file, stderr bytes.Buffer
+	l := New(&console, WithFlags(Lmsglevel), WithLevel(INFO),
+		WithLeveledOutput(&file, TRACE, JSONFormatter{}))
+	l.AddLeveledOutput(&stderr, ERROR, nil)
+
+	l.Trace("trace")
+	l.Info("info")
+	l.Error("error")
+
+	if got, want := console.String(), "[INFO] info\n[ERROR] error\n"; got != want {
+		t.Errorf("console: expected %q, got %q", want, got)
+	}
+	wantFile := `{"level":"TRACE","message":"trace"}` + "\n" +
+		`{"level":"INFO","message":"info"}` + "\n" +
+		`{"level":"ERROR","message":"error"}` + "\n"
+	if got := file.String(); got != wantFile {
+		t.Errorf("file: expected %q, got %q", wantFile, got)
+	}
+	if got, want := stderr.String(), "[ERROR] error\n"; got != want {
+		t.Errorf("stderr: expected %q, got %q", want, got)
+	}
+}
+
+type nopCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *nopCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestLeveledWriteCloser(t *testing.T) {
+	c := &nopCloser{}
+	l := New(Discard, WithFlags(0), WithLevel(PANIC), WithLeveledWriteCloser(c, WARNING, nil))
+	l.Info("info")
+	l.Warning("warning")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.String(), "warning\n"; got != want || !c.closed {
+		t.Errorf("leveled closer: expected %q and closed, got %q closed=%v", want, got, c.closed)
+	}
+}
+
+// entryWriter counts the records and bytes it receives.
+type entryWriter struct {
+	entries, writes int
+}
+
+func (w *entryWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return len(p), nil
+}
+
+func (w *entryWriter) WriteEntry(e *Entry) error {
+	w.entries++
+	return nil
+}
+
+func TestEntryWriterOutput(t *testing.T) {
+	ew := &entryWriter{}
+	var buf bytes.Buffer
+	l := New(ew, WithFlags(0))
+	l.AddOutput(&buf)
+	l.Info("hi")
+	if ew.entries != 1 || ew.writes != 0 {
+		t.Errorf("entry writer: expected 1 entry and no write, got %d and %d", ew.entries, ew.writes)
+	}
+	if got, want := buf.String(), "hi\n"; got != want {
+		t.Errorf("output: expected %q, got %q", want, got)
+	}
+}
+
+type entryWriteCloser struct {
+	entryWriter
+	closed bool
+}
+
+func (w *entryWriteCloser) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestSetOutputReplacesEntryWriters(t *testing.T) {
+	var a, b, leveled bytes.Buffer
+	ew, ewc := &entryWriter{}, &entryWriteCloser{}
+	l := New(&a, WithFlags(0))
+	l.AddOutput(ew)
+	l.AddWriteCloser(ewc)
+	l.AddLeveledOutput(&leveled, INFO, nil)
+	l.SetOutput(&b)
+	l.Info("x")
+	if ew.entries != 0 || ewc.entries != 0 {
+		t.Errorf("entry writers: expected no entry after SetOutput, got %d and %d", ew.entries, ewc.entries)
+	}
+	if !ewc.closed {
+		t.Error("entry write closer: expected to be closed by SetOutput")
+	}
+	if a.Len() != 0 || b.String() != "x\n" || leveled.String() != "x\n" {
+		t.Errorf("outputs: unexpected %q, %q and %q", a.String(), b.String(), leveled.String())
+	}
+}
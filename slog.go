@@ -0,0 +1,183 @@
+package glog
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// slogLevel maps a Level to a slog.Level. A registered level maps to the
+// slog.Level right above that of the level it was registered above.
+func slogLevel(level Level) slog.Level {
+	if info, ok := level.info(); ok {
+		return info.slog
+	}
+	return slog.LevelError + 12
+}
+
+// levelFromSlog maps a slog.Level to the highest Level at or below it.
+func levelFromSlog(level slog.Level) Level {
+	best, found := TRACE, false
+	for i, info := range *levelTable.Load() {
+		if info.slog <= level && (!found || best.Less(Level(i))) {
+			best, found = Level(i), true
+		}
+	}
+	return best
+}
+
+// SlogHandler is a slog.Handler writing through a Logger, so that records
+// from log/slog reach its outputs, formatters and hooks. Attributes become
+// fields; those inside groups are keyed by the dotted group path, such as
+// "request.id".
+type SlogHandler struct {
+	l      *Logger
+	fields Fields
+	group  string
+}
+
+// NewSlogHandler returns a slog.Handler writing to l.
+func NewSlogHandler(l *Logger) *SlogHandler {
+	return &SlogHandler{l: l}
+}
+
+// Enabled reports whether l writes records at the Level matching level.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.l.Enabled(levelFromSlog(level))
+}
+
+// Handle writes r to the logger. The caller is taken from r.PC.
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	l := h.l
+	var fields Fields
+	if n := len(l.fields) + len(h.fields) + r.NumAttrs(); n > 0 {
+		fields = make(Fields, n)
+		for k, v := range l.fields {
+			fields[k] = v
+		}
+		for k, v := range h.fields {
+			fields[k] = v
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			addAttr(fields, h.group, a)
+			return true
+		})
+	}
+	level := levelFromSlog(r.Level)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.enabledLocked(level) {
+		return nil
+	}
+	e := newEntry()
+	defer e.release()
+	e.Logger = l
+	e.Time = r.Time
+	e.Level = level
+	e.Prefix = l.prefix
+	e.Message = r.Message
+	if len(fields) > 0 {
+		e.Fields = fields
+	}
+	if r.PC != 0 && (l.flag&callerFlags != 0 || l.wantsCallerLocked()) {
+		e.setCallerPC(r.PC)
+	}
+	if r.PC != 0 && l.stackLocked(level) {
+		// Release lock while getting the stack, as output does.
+		l.mu.Unlock()
+		e.setStackFrom(r.PC)
+		l.mu.Lock()
+	}
+	return l.emitLocked(e)
+}
+
+// WithAttrs returns a handler adding attrs to every record.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	fields := make(Fields, len(h.fields)+len(attrs))
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	for _, a := range attrs {
+		addAttr(fields, h.group, a)
+	}
+	return &SlogHandler{l: h.l, fields: fields, group: h.group}
+}
+
+// WithGroup returns a handler qualifying the keys of later attributes
+// with name.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{l: h.l, fields: h.fields, group: h.group + name + "."}
+}
+
+// addAttr stores a in fields under its key prefixed by group, flattening
+// groups into dotted keys.
+func addAttr(fields Fields, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		fields[group+a.Key] = a.Value.Any()
+		return
+	}
+	if a.Key != "" {
+		group += a.Key + "."
+	}
+	for _, ga := range a.Value.Group() {
+		addAttr(fields, group, ga)
+	}
+}
+
+// SlogWriter is an EntryWriter forwarding records to a slog.Handler, with
+// fields as attributes. A dotted field key is passed as is, not turned back
+// into a group.
+type SlogWriter struct {
+	h slog.Handler
+}
+
+// NewSlogWriter returns a SlogWriter forwarding to h.
+func NewSlogWriter(h slog.Handler) *SlogWriter {
+	return &SlogWriter{h: h}
+}
+
+// FromSlogHandler returns a Logger whose records are forwarded to h.
+func FromSlogHandler(h slog.Handler, options ...Option) *Logger {
+	return New(NewSlogWriter(h), options...)
+}
+
+// WriteEntry hands e to the handler as a slog.Record.
+func (w *SlogWriter) WriteEntry(e *Entry) error {
+	ctx := context.Background()
+	level := slogLevel(e.Level)
+	if !w.h.Enabled(ctx, level) {
+		return nil
+	}
+	r := slog.NewRecord(e.Time, level, e.Prefix+e.Message, e.PC)
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		r.AddAttrs(slog.Any(k, e.Fields[k]))
+	}
+	return w.h.Handle(ctx, r)
+}
+
+// Write forwards p, without its trailing newline, as a record at INFO.
+func (w *SlogWriter) Write(p []byte) (int, error) {
+	e := Entry{Time: time.Now(), Level: INFO, Message: strings.TrimSuffix(string(p), "\n")}
+	if err := w.WriteEntry(&e); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
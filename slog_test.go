@@ -0,0 +1,90 @@
+package glog
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+)
+
+func TestSlogHandler(t *testing.T) {
+	rec := &entryRecorder{}
+	l := New(Discard, WithLevel(TRACE), WithFormatter(rec))
+	results := func() []map[string]interface{} {
+		var ms []map[string]interface{}
+		for _, e := range rec.entries {
+			m := map[string]interface{}{
+				slog.LevelKey:   slogLevel(e.Level),
+				slog.MessageKey: e.Message,
+			}
+			if !e.Time.IsZero() {
+				m[slog.TimeKey] = e.Time
+			}
+			for k, v := range e.Fields {
+				group, path := m, strings.Split(k, ".")
+				for _, name := range path[:len(path)-1] {
+					sub, ok := group[name].(map[string]interface{})
+					if !ok {
+						sub = map[string]interface{}{}
+						group[name] = sub
+					}
+					group = sub
+				}
+				group[path[len(path)-1]] = v
+			}
+			ms = append(ms, m)
+		}
+		return ms
+	}
+	if err := slogtest.TestHandler(NewSlogHandler(l), results); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSlogHandlerOutput(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lshortfile|Lmsglevel), WithLevel(NOTICE))
+	logger := slog.New(NewSlogHandler(l.WithField("app", "glog"))).WithGroup("req")
+	logger.Info("dropped")
+	logger.Warn("slow", "ms", 250)
+	if got, want := buf.String(), "slog_test.go:50: [WARNING] slow app=glog req.ms=250\n"; got != want {
+		t.Errorf("slog output: expected %q, got %q", want, got)
+	}
+	buf.Reset()
+	l.SetFlags(Lfuncname)
+	logger.Warn("hi")
+	if got, want := buf.String(), "glog.TestSlogHandlerOutput: hi app=glog\n"; got != want {
+		t.Errorf("slog function: expected %q, got %q", want, got)
+	}
+}
+
+func TestSlogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := FromSlogHandler(h, WithLevel(TRACE), WithPrefix("[p] "))
+	l.Trace("hidden by handler")
+	l.WithFields(Fields{"id": 7, "user": "cody"}).Critical("forwarded")
+	if got, want := buf.String(), "level=ERROR+4 msg=\"[p] forwarded\" id=7 user=cody\n"; got != want {
+		t.Errorf("slog writer: expected %q, got %q", want, got)
+	}
+}
+
+func TestSlogLevel(t *testing.T) {
+	for _, level := range AllLevels {
+		if got := levelFromSlog(slogLevel(level)); got != level {
+			t.Errorf("slog level round trip %s: got %s", level, got)
+		}
+	}
+	if got := levelFromSlog(slog.LevelInfo + 1); got != INFO {
+		t.Errorf("slog level INFO+1: expected INFO, got %s", got)
+	}
+}
@@ -0,0 +1,110 @@
+package glog
+
+import (
+	"runtime"
+	"strings"
+)
+
+// maxStackDepth bounds the frames of a stack trace.
+const maxStackDepth = 64
+
+// A Frame is a function call of the stack trace of an Entry.
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// stackLocked reports whether records at level carry a stack trace: those
+// at FATAL and PANIC, and those at or above the level set by SetStacktrace.
+func (l *Logger) stackLocked(level Level) bool {
+	return level == FATAL || level == PANIC || (l.stackOn && level.atLeast(l.stacktrace))
+}
+
+// setStack fills in e.Stack from the caller skip frames above setStack, with
+// the same meaning of skip as runtime.Callers. Functions marked by Helper
+// are skipped at the top, and the runtime frames starting the goroutine at
+// the bottom.
+func (e *Entry) setStack(skip int, helpers *helperSet) {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(skip+1, pcs[:])
+	e.setStackPCs(pcs[:n], helpers)
+}
+
+// setStackFrom fills in e.Stack from the frame of pc down, pc being the
+// caller recorded by slog, or with that frame alone if pc is not on the
+// stack of the calling goroutine.
+func (e *Entry) setStackFrom(pc uintptr) {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(2, pcs[:])
+	for i, p := range pcs[:n] {
+		if p == pc {
+			e.setStackPCs(pcs[i:n], nil)
+			return
+		}
+	}
+	e.setStackPCs([]uintptr{pc}, nil)
+}
+
+// setStackPCs fills in e.Stack from the frames of pcs, skipping those of
+// helpers, which may be nil, at the top.
+func (e *Entry) setStackPCs(pcs []uintptr, helpers *helperSet) {
+	frames := runtime.CallersFrames(pcs)
+	stack := make([]Frame, 0, len(pcs))
+	for {
+		frame, more := frames.Next()
+		if frame.PC == 0 {
+			break
+		}
+		if len(stack) > 0 || helpers == nil || helpers.empty() || !helpers.contains(frame.Function) {
+			stack = append(stack, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	for len(stack) > 0 && strings.HasPrefix(stack[len(stack)-1].Function, "runtime.") {
+		stack = stack[:len(stack)-1]
+	}
+	e.Stack = stack
+}
+
+// appendStack writes stack on the lines following a record, each frame as
+// its function and, indented once more, its file and line.
+func appendStack(buf *[]byte, stack []Frame) {
+	for _, f := range stack {
+		*buf = append(*buf, "\n\t"...)
+		*buf = append(*buf, f.Function...)
+		*buf = append(*buf, "\n\t\t"...)
+		*buf = append(*buf, f.File...)
+		*buf = append(*buf, ':')
+		itoa(buf, f.Line, -1)
+	}
+}
+
+// Stacktrace returns the level set with SetStacktrace and whether it is set.
+func (l *Logger) Stacktrace() (Level, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stacktrace, l.stackOn
+}
+
+// SetStacktrace adds the stack of the caller to records at or above level.
+// Records at FATAL and PANIC always carry it.
+func (l *Logger) SetStacktrace(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stacktrace, l.stackOn = level, true
+}
+
+// ResetStacktrace removes the stack from records below FATAL.
+func (l *Logger) ResetStacktrace() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stackOn = false
+}
+
+// SetStacktrace sets the stack trace level of the standard logger.
+func SetStacktrace(level Level) {
+	glog.SetStacktrace(level)
+}
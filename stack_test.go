@@ -0,0 +1,97 @@
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestStacktrace(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lmsglevel), WithStacktrace(ERROR))
+	l.Warning("no stack")
+	logViaHelper(l, "stack")
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "[WARNING] no stack" || lines[1] != "[INFO] stack" {
+		t.Fatalf("stacktrace: unexpected %q", buf.String())
+	}
+
+	buf.Reset()
+	l.Error("stack\n")
+	lines = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) < 5 || lines[0] != "[ERROR] stack" ||
+		lines[1] != "\tgithub.com/CodyGuo/glog.TestStacktrace" || !strings.HasSuffix(lines[2], "/stack_test.go:22") {
+		t.Fatalf("stacktrace: unexpected %q", buf.String())
+	}
+	if last := lines[len(lines)-2]; strings.Contains(last, "runtime.") {
+		t.Errorf("stacktrace: runtime frames should be trimmed, got %q", last)
+	}
+
+	buf.Reset()
+	l.ResetStacktrace()
+	l.Error("no stack")
+	if got := buf.String(); got != "[ERROR] no stack\n" {
+		t.Errorf("reset stacktrace: unexpected %q", got)
+	}
+}
+
+func errorViaHelper(l *Logger, s string) {
+	l.Helper()
+	l.Error(s)
+}
+
+func TestStacktraceJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lmsgjson), WithStacktrace(ERROR))
+	errorViaHelper(l, "json")
+	var record struct {
+		Message string  `json:"message"`
+		Stack   []Frame `json:"stack"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Stack) == 0 || record.Stack[0].Function != "github.com/CodyGuo/glog.TestStacktraceJSON" ||
+		!strings.HasSuffix(record.Stack[0].File, "/stack_test.go") || record.Stack[0].Line != 48 {
+		t.Errorf("stacktrace json: unexpected %+v", record)
+	}
+}
+
+func TestPanicStack(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lmsglevel))
+	func() {
+		defer func() { recover() }()
+		l.Panic("boom")
+	}()
+	if got := buf.String(); !strings.HasPrefix(got, "[PANIC] boom\n\tgithub.com/CodyGuo/glog.TestPanicStack.func1\n") {
+		t.Errorf("panic stack: unexpected %q", got)
+	}
+}
+
+func TestSlogStack(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lmsglevel), WithStacktrace(ERROR))
+	logger := slog.New(NewSlogHandler(l))
+	logger.Warn("no stack")
+	logger.Error("stack")
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) < 4 || lines[0] != "[WARNING] no stack" || lines[1] != "[ERROR] stack" ||
+		lines[2] != "\tgithub.com/CodyGuo/glog.TestSlogStack" || !strings.HasSuffix(lines[3], "/stack_test.go:79") {
+		t.Errorf("slog stack: unexpected %q", buf.String())
+	}
+}
+
+func TestOutputDepthFatal(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lshortfile|Lmsglevel))
+	// OutputDepth logs FATAL records, with their stack, without exiting.
+	l.OutputDepth(0, FATAL, "%s", "no exit")
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) < 3 || lines[0] != "stack_test.go:91: [FATAL] no exit" ||
+		lines[1] != "\tgithub.com/CodyGuo/glog.TestOutputDepthFatal" {
+		t.Errorf("output depth: unexpected %q", buf.String())
+	}
+}
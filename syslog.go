@@ -0,0 +1,385 @@
+package glog
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Facility is the syslog facility of the records of a SyslogWriter.
+type Facility int
+
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLpr
+	FacilityNews
+	FacilityUucp
+	FacilityCron
+	FacilityAuthpriv
+	FacilityFtp
+	_ // ntp
+	_ // log audit
+	_ // log alert
+	_ // clock
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// syslogTimeout bounds dialing and writing to a syslog server.
+const syslogTimeout = 10 * time.Second
+
+// localSyslogPaths are the sockets tried by a local SyslogWriter.
+var localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogOption configures a SyslogWriter.
+type SyslogOption func(*syslogOptions)
+
+type syslogOptions struct {
+	facility Facility
+	appName  string
+	hostname string
+	sdID     string
+	rfc3164  bool
+	tls      *tls.Config
+}
+
+// SyslogFacility sets the facility of records. Default FacilityUser.
+func SyslogFacility(facility Facility) SyslogOption {
+	return func(o *syslogOptions) {
+		o.facility = facility
+	}
+}
+
+// SyslogAppName sets the APP-NAME, or TAG in RFC 3164, of records. Default
+// the base name of the program.
+func SyslogAppName(name string) SyslogOption {
+	return func(o *syslogOptions) {
+		o.appName = name
+	}
+}
+
+// SyslogHostname sets the HOSTNAME of records. Default os.Hostname.
+func SyslogHostname(name string) SyslogOption {
+	return func(o *syslogOptions) {
+		o.hostname = name
+	}
+}
+
+// SyslogStructuredData sets the SD-ID of the structured data element that
+// carries the fields of records in RFC 5424. Default "fields@32473".
+func SyslogStructuredData(id string) SyslogOption {
+	return func(o *syslogOptions) {
+		o.sdID = id
+	}
+}
+
+// SyslogRFC3164 writes records in the BSD syslog format of RFC 3164 rather
+// than RFC 5424. Fields are appended to the message as key=value pairs.
+func SyslogRFC3164() SyslogOption {
+	return func(o *syslogOptions) {
+		o.rfc3164 = true
+	}
+}
+
+// SyslogTLS sets the configuration of the "tls" network.
+func SyslogTLS(config *tls.Config) SyslogOption {
+	return func(o *syslogOptions) {
+		o.tls = config
+	}
+}
+
+// SyslogWriter is an EntryWriter sending records to a syslog server, with
+// their Level mapped to a syslog severity: TRACE and DEBUG to debug, INFO to
+// info, NOTICE to notice, WARNING to warning, ERROR to err, CRITICAL to crit,
+// FATAL to alert and PANIC to emerg. Registered levels use the severity set
+// with RegisterLevel. Plain writes are sent at info.
+//
+// Messages are framed by octet counting (RFC 6587) on the "tcp", "tcp4",
+// "tcp6" and "tls" networks, terminated by a newline on "unix" and sent one
+// per datagram on "udp" and "unixgram". A failed connection is dialed again
+// on the next write.
+type SyslogWriter struct {
+	mu       sync.Mutex
+	network  string
+	addr     string
+	opts     syslogOptions
+	local    bool
+	closed   bool
+	conn     net.Conn
+	buf      []byte // the record being sent
+	frame    []byte // the record with its framing
+	hostname string
+}
+
+// NewSyslogWriter connects to the syslog server at addr on network, one of
+// "udp", "tcp", "tls", "unix" and "unixgram". With an empty network and addr
+// it connects to the local syslog socket, such as /dev/log, and writes in
+// the RFC 3164 format without hostname expected there.
+func NewSyslogWriter(network, addr string, options ...SyslogOption) (*SyslogWriter, error) {
+	w := &SyslogWriter{
+		network: network,
+		addr:    addr,
+		opts: syslogOptions{
+			facility: FacilityUser,
+			appName:  filepath.Base(os.Args[0]),
+			sdID:     "fields@32473",
+		},
+		local: network == "" && addr == "",
+	}
+	for _, option := range options {
+		option(&w.opts)
+	}
+	if w.opts.hostname == "" {
+		w.opts.hostname, _ = os.Hostname()
+	}
+	if w.local {
+		w.opts.rfc3164 = true
+	}
+	w.hostname = syslogField(w.opts.hostname, 255)
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// connect dials the server. It must be called with w.mu held.
+func (w *SyslogWriter) connect() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	if w.local {
+		for _, path := range localSyslogPaths {
+			for _, network := range []string{"unixgram", "unix"} {
+				if conn, err := net.DialTimeout(network, path, syslogTimeout); err == nil {
+					w.conn = conn
+					return nil
+				}
+			}
+		}
+		return errors.New("glog: no local syslog socket found")
+	}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if w.network == "tls" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: syslogTimeout}, "tcp", w.addr, w.opts.tls)
+	} else {
+		conn, err = net.DialTimeout(w.network, w.addr, syslogTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// WriteEntry sends e at the syslog severity of its level.
+func (w *SyslogWriter) WriteEntry(e *Entry) error {
+	severity := 6
+	if info, ok := e.Level.info(); ok {
+		severity = info.syslog
+	}
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return w.send(severity, t, e.Prefix+e.Message, e.Fields)
+}
+
+// Write sends p at info.
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	if err := w.send(6, time.Now(), string(p), nil); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *SyslogWriter) send(severity int, t time.Time, msg string, fields Fields) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	msg = strings.TrimRight(msg, "\n")
+	w.buf = w.buf[:0]
+	if w.opts.rfc3164 {
+		w.format3164(severity, t, msg, fields)
+	} else {
+		w.format5424(severity, t, msg, fields)
+	}
+	if w.conn != nil {
+		if err := w.write(); err == nil {
+			return nil
+		}
+	}
+	if err := w.connect(); err != nil {
+		return err
+	}
+	return w.write()
+}
+
+// write sends the record in w.buf, framed by octet counting on the "tcp"
+// networks and "tls" and terminated by a newline on unix stream sockets.
+func (w *SyslogWriter) write() error {
+	p := w.buf
+	if strings.HasPrefix(w.network, "tcp") || w.network == "tls" {
+		w.frame = strconv.AppendInt(w.frame[:0], int64(len(p)), 10)
+		w.frame = append(w.frame, ' ')
+		w.frame = append(w.frame, p...)
+		p = w.frame
+	} else if w.conn.LocalAddr().Network() == "unix" {
+		w.frame = append(append(w.frame[:0], p...), '\n')
+		p = w.frame
+	}
+	w.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+	_, err := w.conn.Write(p)
+	return err
+}
+
+// priority writes the PRI part of a record.
+func (w *SyslogWriter) priority(severity int) {
+	w.buf = append(w.buf, '<')
+	w.buf = strconv.AppendInt(w.buf, int64(w.opts.facility)*8+int64(severity), 10)
+	w.buf = append(w.buf, '>')
+}
+
+// format5424 lays out a record as
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID key="value"...] MSG.
+func (w *SyslogWriter) format5424(severity int, t time.Time, msg string, fields Fields) {
+	w.priority(severity)
+	w.buf = append(w.buf, "1 "...)
+	w.buf = t.AppendFormat(w.buf, "2006-01-02T15:04:05.000000Z07:00")
+	w.buf = append(w.buf, ' ')
+	w.buf = append(w.buf, nilValue(w.hostname)...)
+	w.buf = append(w.buf, ' ')
+	w.buf = append(w.buf, nilValue(syslogField(w.opts.appName, 48))...)
+	w.buf = append(w.buf, ' ')
+	w.buf = strconv.AppendInt(w.buf, int64(os.Getpid()), 10)
+	w.buf = append(w.buf, " - "...)
+	if len(fields) == 0 {
+		w.buf = append(w.buf, '-')
+	} else {
+		w.appendStructuredData(fields)
+	}
+	if msg != "" {
+		w.buf = append(w.buf, ' ')
+		w.buf = append(w.buf, msg...)
+	}
+}
+
+// appendStructuredData writes fields as one SD-ELEMENT, with names made
+// valid SD-NAMEs and values escaped.
+func (w *SyslogWriter) appendStructuredData(fields Fields) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	w.buf = append(w.buf, '[')
+	w.buf = append(w.buf, w.opts.sdID...)
+	for _, k := range keys {
+		w.buf = append(w.buf, ' ')
+		w.buf = append(w.buf, sdName(k)...)
+		w.buf = append(w.buf, `="`...)
+		for _, c := range []byte(fmt.Sprint(fields[k])) {
+			if c == '"' || c == '\\' || c == ']' {
+				w.buf = append(w.buf, '\\')
+			}
+			w.buf = append(w.buf, c)
+		}
+		w.buf = append(w.buf, '"')
+	}
+	w.buf = append(w.buf, ']')
+}
+
+// format3164 lays out a record as <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG,
+// without HOSTNAME on the local socket.
+func (w *SyslogWriter) format3164(severity int, t time.Time, msg string, fields Fields) {
+	w.priority(severity)
+	w.buf = t.AppendFormat(w.buf, time.Stamp)
+	w.buf = append(w.buf, ' ')
+	if !w.local {
+		w.buf = append(w.buf, nilValue(w.hostname)...)
+		w.buf = append(w.buf, ' ')
+	}
+	w.buf = append(w.buf, syslogField(w.opts.appName, 32)...)
+	w.buf = append(w.buf, '[')
+	w.buf = strconv.AppendInt(w.buf, int64(os.Getpid()), 10)
+	w.buf = append(w.buf, "]: "...)
+	w.buf = append(w.buf, msg...)
+	if len(fields) > 0 {
+		appendFields(&w.buf, fields)
+	}
+}
+
+// Close closes the connection. Later writes fail with os.ErrClosed.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// syslogField returns s limited to printable US-ASCII without spaces and to
+// max bytes, as required of HOSTNAME and APP-NAME.
+func syslogField(s string, max int) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c < 33 || c > 126 {
+			b[i] = '_'
+		}
+	}
+	if len(b) > max {
+		b = b[:max]
+	}
+	return string(b)
+}
+
+// sdName returns k as an SD-NAME: at most 32 printable US-ASCII characters
+// other than '=', ' ', ']' and '"'.
+func sdName(k string) string {
+	b := []byte(syslogField(k, 32))
+	for i, c := range b {
+		if c == '=' || c == ']' || c == '"' {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+// nilValue returns "-", the NILVALUE of RFC 5424, for an empty s.
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,180 @@
+package glog
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readDatagram(t *testing.T, conn net.PacketConn) string {
+	t.Helper()
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestSyslogRFC5424(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	w, err := NewSyslogWriter("udp", conn.LocalAddr().String(),
+		SyslogFacility(FacilityLocal0), SyslogHostname("host"), SyslogAppName("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(Discard, WithLevel(TRACE), WithMultiWriteCloser(w))
+	defer l.Close()
+
+	l.WithFields(Fields{"user": `a"b]`, "id key": 7}).Warning("hello\n")
+	re := regexp.MustCompile(`^<132>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}(Z|[+-]\d\d:\d\d) host app ` +
+		strconv.Itoa(os.Getpid()) + ` - \[fields@32473 id_key="7" user="a\\"b\\]"\] hello$`)
+	if got := readDatagram(t, conn); !re.MatchString(got) {
+		t.Errorf("rfc 5424: unexpected %q", got)
+	}
+
+	for level, severity := range map[Level]int{TRACE: 7, DEBUG: 7, INFO: 6, NOTICE: 5, ERROR: 3, CRITICAL: 2} {
+		l.Output(level, "%s", "m")
+		want := "<" + strconv.Itoa(16*8+severity) + ">1 "
+		if got := readDatagram(t, conn); !strings.HasPrefix(got, want) || !strings.HasSuffix(got, " - - m") {
+			t.Errorf("severity of %s: expected %q, got %q", level, want, got)
+		}
+	}
+}
+
+func TestSyslogRFC3164(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+	conn, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	w, err := NewSyslogWriter("unixgram", path, SyslogRFC3164(), SyslogHostname("host"), SyslogAppName("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteEntry(&Entry{Level: ERROR, Message: "failed", Fields: Fields{"k": "v w"}})
+	re := regexp.MustCompile(`^<11>[A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d host app\[\d+\]: failed k="v w"$`)
+	if got := readDatagram(t, conn); !re.MatchString(got) {
+		t.Errorf("rfc 3164: unexpected %q", got)
+	}
+	w.Close()
+	if _, err := w.Write([]byte("closed")); err != os.ErrClosed {
+		t.Errorf("closed: expected os.ErrClosed, got %v", err)
+	}
+
+	paths := localSyslogPaths
+	localSyslogPaths = []string{filepath.Join(dir, "missing"), path}
+	defer func() { localSyslogPaths = paths }()
+	local, err := NewSyslogWriter("", "", SyslogAppName("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+	local.Write([]byte("local\n"))
+	re = regexp.MustCompile(`^<14>[A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d app\[\d+\]: local$`)
+	if got := readDatagram(t, conn); !re.MatchString(got) {
+		t.Errorf("local: unexpected %q", got)
+	}
+}
+
+// readFrame reads an octet-counted message.
+func readFrame(r *bufio.Reader) (string, error) {
+	size, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(size, " "))
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, n)
+	_, err = io.ReadFull(r, msg)
+	return string(msg), err
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	conns := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+
+	w, err := NewSyslogWriter("tcp", ln.Addr().String(), SyslogHostname("host"), SyslogAppName("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	first := <-conns
+	w.Write([]byte("one"))
+	w.Write([]byte("two"))
+	r := bufio.NewReader(first)
+	for _, want := range []string{"one", "two"} {
+		msg, err := readFrame(r)
+		if err != nil || !strings.HasSuffix(msg, " host app "+strconv.Itoa(os.Getpid())+" - - "+want) {
+			t.Fatalf("tcp frame: expected %q, got %q (%v)", want, msg, err)
+		}
+	}
+
+	// Writes to a connection closed by the server fail after a while, and
+	// the writer then dials again.
+	first.Close()
+	var second net.Conn
+	deadline := time.After(5 * time.Second)
+	for second == nil {
+		w.Write([]byte("again"))
+		select {
+		case second = <-conns:
+		case <-deadline:
+			t.Fatal("tcp: no reconnection")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	defer second.Close()
+	msg, err := readFrame(bufio.NewReader(second))
+	if err != nil || !strings.HasSuffix(msg, " again") {
+		t.Errorf("tcp reconnect: unexpected %q (%v)", msg, err)
+	}
+
+	w4, err := NewSyslogWriter("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w4.Close()
+	third := <-conns
+	defer third.Close()
+	w4.Write([]byte("four"))
+	msg, err = readFrame(bufio.NewReader(third))
+	if err != nil || !strings.HasSuffix(msg, " four") {
+		t.Errorf("tcp4 frame: unexpected %q (%v)", msg, err)
+	}
+}
@@ -0,0 +1,64 @@
+package glog
+
+import "time"
+
+// Layouts for WithTimeFormat besides those of the time package. The Unix
+// layouts write the time elapsed since January 1, 1970 UTC as an integer.
+const (
+	RFC3339Milli  = "2006-01-02T15:04:05.000Z07:00"
+	TimeUnix      = "unix"
+	TimeUnixMilli = "unixmilli"
+	TimeUnixMicro = "unixmicro"
+	TimeUnixNano  = "unixnano"
+)
+
+// epochFormat reports whether layout is one of the Unix layouts.
+func epochFormat(layout string) bool {
+	switch layout {
+	case TimeUnix, TimeUnixMilli, TimeUnixMicro, TimeUnixNano:
+		return true
+	}
+	return false
+}
+
+// TimeFormat returns the layout set with SetTimeFormat.
+func (l *Logger) TimeFormat() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.timeFormat
+}
+
+// SetTimeFormat writes the time of records in layout, such as
+// time.RFC3339Nano or TimeUnixMilli, instead of the layout chosen by the
+// Ldate, Ltime and Lmicroseconds flags. Records carry a time whatever the
+// flags. An empty layout goes back to the flags.
+func (l *Logger) SetTimeFormat(layout string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.timeFormat = layout
+}
+
+// Location returns the location set with SetLocation, nil for local time.
+func (l *Logger) Location() *time.Location {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.location
+}
+
+// SetLocation writes the time of records in loc rather than local time.
+// LUTC takes precedence over it.
+func (l *Logger) SetLocation(loc *time.Location) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.location = loc
+}
+
+// SetTimeFormat sets the time layout of the standard logger.
+func SetTimeFormat(layout string) {
+	glog.SetTimeFormat(layout)
+}
+
+// SetLocation sets the time location of the standard logger.
+func SetLocation(loc *time.Location) {
+	glog.SetLocation(loc)
+}
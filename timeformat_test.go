@@ -0,0 +1,63 @@
+package glog
+
+import (
+	"testing"
+	"time"
+)
+
+func formatTime(f Formatter, l *Logger, t time.Time) string {
+	var buf []byte
+	f.Format(&Entry{Logger: l, Time: t, Message: "m"}, &buf)
+	return string(buf)
+}
+
+func TestTimeFormat(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	now := time.Date(2009, 1, 23, 1, 23, 23, 123456789, time.UTC)
+	for _, tc := range []struct {
+		options []Option
+		text    string
+		json    string
+	}{
+		{[]Option{WithFlags(LstdFlags | Lmicroseconds)}, "2009/01/23 01:23:23.123456 m", `{"time":"2009/01/23 01:23:23.123456","message":"m"}`},
+		{[]Option{WithFlags(Ldate)}, "2009/01/23 m", `{"time":"2009/01/23","message":"m"}`},
+		{[]Option{WithFlags(0), WithTimeFormat(time.RFC3339Nano)}, "2009-01-23T01:23:23.123456789Z m", `{"time":"2009-01-23T01:23:23.123456789Z","message":"m"}`},
+		{[]Option{WithFlags(0), WithTimeFormat(RFC3339Milli), WithLocation(loc)}, "2009-01-23T09:23:23.123+08:00 m", `{"time":"2009-01-23T09:23:23.123+08:00","message":"m"}`},
+		{[]Option{WithFlags(LUTC), WithTimeFormat(RFC3339Milli), WithLocation(loc)}, "2009-01-23T01:23:23.123Z m", `{"time":"2009-01-23T01:23:23.123Z","message":"m"}`},
+		{[]Option{WithFlags(Ltime), WithLocation(loc)}, "09:23:23 m", `{"time":"09:23:23","message":"m"}`},
+		{[]Option{WithTimeFormat(TimeUnix)}, "1232673803 m", `{"time":1232673803,"message":"m"}`},
+		{[]Option{WithTimeFormat(TimeUnixMilli)}, "1232673803123 m", `{"time":1232673803123,"message":"m"}`},
+		{[]Option{WithTimeFormat(TimeUnixNano)}, "1232673803123456789 m", `{"time":1232673803123456789,"message":"m"}`},
+	} {
+		l := New(Discard, tc.options...)
+		if got := formatTime(TextFormatter{}, l, now); got != tc.text {
+			t.Errorf("text time: expected %q, got %q", tc.text, got)
+		}
+		if got := formatTime(JSONFormatter{}, l, now); got != tc.json {
+			t.Errorf("json time: expected %q, got %q", tc.json, got)
+		}
+	}
+}
+
+func TestSetTimeFormat(t *testing.T) {
+	l := New(Discard)
+	l.SetTimeFormat(time.Kitchen)
+	l.SetLocation(time.UTC)
+	if l.TimeFormat() != time.Kitchen || l.Location() != time.UTC {
+		t.Errorf("SetTimeFormat: got %q in %v", l.TimeFormat(), l.Location())
+	}
+	if got := formatTime(TextFormatter{}, l, time.Date(2009, 1, 23, 13, 4, 0, 0, time.UTC)); got != "1:04PM m" {
+		t.Errorf("SetTimeFormat: unexpected %q", got)
+	}
+}
+
+func BenchmarkTimeDefault(b *testing.B) {
+	l := New(Discard, WithFlags(LstdFlags|Lmicroseconds))
+	e := &Entry{Logger: l, Time: time.Now(), Message: "m"}
+	buf := make([]byte, 0, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = buf[:0]
+		TextFormatter{}.Format(e, &buf)
+	}
+}
@@ -0,0 +1,329 @@
+package glog
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeRotatingFile is an io.WriteCloser that opens a new file whenever the
+// calendar period named by its pattern changes. The pattern is a file name
+// containing strftime conversions:
+//
+//	%Y  year              %y  year without century
+//	%m  month (01-12)     %b  abbreviated month name
+//	%d  day (01-31)       %j  day of the year (001-366)
+//	%H  hour (00-23)      %M  minute (00-59)
+//	%S  second (00-59)    %%  a literal '%'
+//
+// The finest conversion sets the period, so "app-%Y%m%d-%H.log" rotates
+// hourly and "app-%Y%m%d.log" daily. Like RotatingFile it is safe for
+// concurrent use and is closed by (*Logger).Close once handed to it.
+//
+// The records of a logger go to the file of the period of their time, in
+// the location the logger shows it in: UTC with LUTC, or the location set
+// with SetLocation. Plain writes follow the local clock unless UTC is set.
+type TimeRotatingFile struct {
+	mu      sync.Mutex
+	pattern string
+	opts    rotateOptions
+	file    *os.File
+	name    string
+	next    time.Time
+	loc     *time.Location // of the times deciding the period
+	closed  bool
+	worker  *backgroundWorker
+	errs    chan error
+}
+
+// NewTimeRotatingFile opens the file named by pattern for the current time
+// and returns a TimeRotatingFile writing to it. MaxBackups and MaxAge apply
+// to the older files matching the pattern; MaxSize and TimestampBackups
+// are ignored.
+func NewTimeRotatingFile(pattern string, options ...RotateOption) (*TimeRotatingFile, error) {
+	r := &TimeRotatingFile{
+		pattern: pattern,
+		opts:    rotateOptions{perm: 0644},
+	}
+	for _, option := range options {
+		option(&r.opts)
+	}
+	if r.opts.compress != nil {
+		r.worker = newBackgroundWorker()
+		r.errs = r.worker.errs
+	} else {
+		r.errs = make(chan error, errorBuffer)
+	}
+	if err := r.rotate(r.opts.clock()); err != nil {
+		if r.worker != nil {
+			r.worker.close()
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// Errors returns a channel reporting the failures of the rotations made by
+// Write and of background compression and cleanup. It is closed by Close.
+// When it is not drained the oldest errors are discarded.
+func (r *TimeRotatingFile) Errors() <-chan error {
+	return r.errs
+}
+
+// Name returns the name of the file being written.
+func (r *TimeRotatingFile) Name() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.name
+}
+
+// Write appends p to the file of the current period, opening it first if
+// the period has changed since the previous write. When the rotation fails
+// but a file is still open, p is written to it and the error is reported on
+// Errors.
+func (r *TimeRotatingFile) Write(p []byte) (int, error) {
+	return r.write(p, r.opts.clock())
+}
+
+// writeAt writes a record of a logger to the file of the period of t.
+func (r *TimeRotatingFile) writeAt(p []byte, t time.Time) (int, error) {
+	return r.write(p, r.opts.recordClock(t))
+}
+
+// write appends p to the file of the period of now, whose location may
+// differ from that of the previous write when the logger has changed it.
+func (r *TimeRotatingFile) write(p []byte, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if !r.next.IsZero() && (!now.Before(r.next) || now.Location() != r.loc) {
+		if err := r.rotate(now); err != nil {
+			if r.file == nil {
+				return 0, err
+			}
+			reportError(r.errs, err)
+		}
+	}
+	return r.file.Write(p)
+}
+
+// Close closes the underlying file and waits for pending compressions.
+func (r *TimeRotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	r.closed = true
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	if r.worker != nil {
+		r.worker.close()
+	} else {
+		close(r.errs)
+	}
+	return err
+}
+
+// rotate must be called with r.mu held. It switches to the file for now;
+// r.file is only left nil if that file could not be opened. A failure to
+// replace the symlink is returned once compression and cleanup are done or
+// queued.
+func (r *TimeRotatingFile) rotate(now time.Time) error {
+	name := strftime(r.pattern, now)
+	if r.file != nil && name == r.name {
+		// Only the location changed, within the same period.
+		r.next, r.loc = nextPeriod(r.pattern, now), now.Location()
+		return nil
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, r.opts.perm)
+	if err != nil {
+		return err
+	}
+	old := r.name
+	if r.file != nil {
+		r.file.Close()
+	}
+	r.file = f
+	r.name = name
+	r.next, r.loc = nextPeriod(r.pattern, now), now.Location()
+	var linkErr error
+	if r.opts.symlink != "" {
+		linkErr = replaceSymlink(name, r.opts.symlink)
+	}
+	if r.worker == nil {
+		if err := r.cleanup(name); linkErr == nil {
+			return err
+		}
+		return linkErr
+	}
+	if old != "" && old != name {
+		r.worker.queue(func() error {
+			if err := compressFile(r.opts.compress, old, old+r.opts.compress.Extension()); err != nil {
+				return err
+			}
+			return r.cleanup(name)
+		})
+	}
+	return linkErr
+}
+
+// cleanup removes the files of earlier periods, compressed or not, beyond
+// MaxBackups or MaxAge. current is the file being written.
+func (r *TimeRotatingFile) cleanup(current string) error {
+	if r.opts.maxBackups <= 0 && r.opts.maxAge <= 0 {
+		return nil
+	}
+	glob := patternGlob(r.pattern)
+	ext := r.opts.extension()
+	backups := listBackups(glob+"*", func(name string) bool {
+		if name == current {
+			return false
+		}
+		ok, _ := filepath.Match(glob, strings.TrimSuffix(name, ext))
+		return ok
+	})
+	return r.opts.removeExpired(backups)
+}
+
+// replaceSymlink atomically points link at target, which is made relative
+// to the directory of link when both share it.
+func replaceSymlink(target, link string) error {
+	if rel, err := filepath.Rel(filepath.Dir(link), target); err == nil && !strings.HasPrefix(rel, "..") {
+		target = rel
+	}
+	tmp := link + ".tmp" + strconv.Itoa(os.Getpid())
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+var monthAbbr = [...]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+// strftime expands the conversions of pattern for t.
+func strftime(pattern string, t time.Time) string {
+	buf := make([]byte, 0, len(pattern)+16)
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != '%' || i+1 == len(pattern) {
+			buf = append(buf, c)
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y':
+			itoa(&buf, t.Year(), 4)
+		case 'y':
+			itoa(&buf, t.Year()%100, 2)
+		case 'm':
+			itoa(&buf, int(t.Month()), 2)
+		case 'b':
+			buf = append(buf, monthAbbr[t.Month()-1]...)
+		case 'd':
+			itoa(&buf, t.Day(), 2)
+		case 'j':
+			itoa(&buf, t.YearDay(), 3)
+		case 'H':
+			itoa(&buf, t.Hour(), 2)
+		case 'M':
+			itoa(&buf, t.Minute(), 2)
+		case 'S':
+			itoa(&buf, t.Second(), 2)
+		case '%':
+			buf = append(buf, '%')
+		default:
+			buf = append(buf, '%', pattern[i])
+		}
+	}
+	return string(buf)
+}
+
+// patternGlob turns the conversions of pattern into wildcards.
+func patternGlob(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c == '%' && i+1 < len(pattern) {
+			i++
+			if pattern[i] == '%' {
+				b.WriteByte('%')
+			} else {
+				b.WriteByte('*')
+			}
+			continue
+		}
+		b.WriteString(globEscape(string(c)))
+	}
+	return b.String()
+}
+
+// nextPeriod returns the start of the period following the one containing t,
+// or the zero time if pattern has no time conversion.
+func nextPeriod(pattern string, t time.Time) time.Time {
+	const (
+		none = iota
+		year
+		month
+		day
+		hour
+		minute
+		second
+	)
+	period := none
+	for i := 0; i+1 < len(pattern); i++ {
+		if pattern[i] != '%' {
+			continue
+		}
+		i++
+		p := none
+		switch pattern[i] {
+		case 'Y', 'y':
+			p = year
+		case 'm', 'b':
+			p = month
+		case 'd', 'j':
+			p = day
+		case 'H':
+			p = hour
+		case 'M':
+			p = minute
+		case 'S':
+			p = second
+		}
+		if p > period {
+			period = p
+		}
+	}
+	y, mo, d := t.Date()
+	h, mi, s := t.Clock()
+	loc := t.Location()
+	switch period {
+	case year:
+		return time.Date(y+1, 1, 1, 0, 0, 0, 0, loc)
+	case month:
+		return time.Date(y, mo+1, 1, 0, 0, 0, 0, loc)
+	case day:
+		return time.Date(y, mo, d+1, 0, 0, 0, 0, loc)
+	case hour:
+		return time.Date(y, mo, d, h+1, 0, 0, 0, loc)
+	case minute:
+		return time.Date(y, mo, d, h, mi+1, 0, 0, loc)
+	case second:
+		return time.Date(y, mo, d, h, mi, s+1, 0, loc)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,141 @@
+package glog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStrftime(t *testing.T) {
+	tm := time.Date(2020, 4, 7, 3, 5, 9, 0, time.UTC)
+	if got, want := strftime("app-%Y%m%d-%H%M%S.%y.%j.%b.%%.%q.log", tm), "app-20200407-030509.20.098.Apr.%.%q.log"; got != want {
+		t.Errorf("strftime: expected %q, got %q", want, got)
+	}
+	if got, want := nextPeriod("app-%Y%m%d.log", tm), time.Date(2020, 4, 8, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("daily period: expected %v, got %v", want, got)
+	}
+	if got := nextPeriod("app.log", tm); !got.IsZero() {
+		t.Errorf("no period: expected zero time, got %v", got)
+	}
+}
+
+func TestTimeRotatingFile(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	now := time.Date(2020, 4, 27, 22, 59, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	link := filepath.Join(dir, "current")
+	r, err := NewTimeRotatingFile(filepath.Join(dir, "app-%Y%m%d-%H.log"),
+		UTC(), Clock(clock), Symlink(link), MaxBackups(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(Discard, WithFlags(0), WithWriteCloser(r))
+	l.Info("22h")
+	now = now.Add(time.Minute)
+	l.Info("23h")
+	now = now.Add(time.Hour)
+	l.Info("00h")
+	l.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "app-20200427-22.log")); !os.IsNotExist(err) {
+		t.Errorf("oldest file should have been removed, stat error: %v", err)
+	}
+	if got := readFile(t, filepath.Join(dir, "app-20200427-23.log")); got != "23h\n" {
+		t.Errorf("23h file: expected %q, got %q", "23h\n", got)
+	}
+	if got := readFile(t, link); got != "00h\n" {
+		t.Errorf("symlink: expected %q, got %q", "00h\n", got)
+	}
+	if target, _ := os.Readlink(link); target != "app-20200428-00.log" {
+		t.Errorf("symlink target: expected app-20200428-00.log, got %q", target)
+	}
+}
+
+func TestTimeRotatingFileSymlinkFailure(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	now := time.Date(2020, 4, 27, 22, 0, 0, 0, time.UTC)
+	link := filepath.Join(dir, "current")
+	r, err := NewTimeRotatingFile(filepath.Join(dir, "app-%Y%m%d-%H.log"),
+		UTC(), Clock(func() time.Time { return now }), Symlink(link), MaxBackups(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// A non-empty directory in place of the symlink cannot be replaced.
+	os.Remove(link)
+	if err := os.MkdirAll(filepath.Join(link, "busy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("22h\n"))
+	now = now.Add(time.Hour)
+	r.Write([]byte("23h\n"))
+	now = now.Add(time.Hour)
+	if n, err := r.Write([]byte("00h\n")); n != 4 || err != nil {
+		t.Errorf("write: expected 4 bytes and no error, got %d and %v", n, err)
+	}
+	if n := len(r.Errors()); n != 2 {
+		t.Errorf("errors: expected a symlink error for each rotation, got %d", n)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app-20200427-22.log")); !os.IsNotExist(err) {
+		t.Errorf("oldest file should have been removed, stat error: %v", err)
+	}
+	if got := readFile(t, r.Name()); got != "00h\n" {
+		t.Errorf("current file: expected %q, got %q", "00h\n", got)
+	}
+}
+
+func TestTimeRotatingFileLoggerTime(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	// 23:30 in a zone ten hours ahead of UTC.
+	now := time.Date(2020, 4, 27, 13, 30, 0, 0, time.UTC)
+	r, err := NewTimeRotatingFile(filepath.Join(dir, "app-%Y%m%d.log"), Clock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(Discard, WithFlags(LUTC), WithWriteCloser(r))
+	l.Info("utc")
+	l.SetFlags(0)
+	l.SetLocation(time.FixedZone("UTC+10", 10*60*60))
+	l.Info("plus10")
+	now = now.Add(time.Hour)
+	l.Info("plus10 next day")
+	l.SetFlags(LUTC)
+	l.Info("utc same day")
+	l.Close()
+
+	for name, want := range map[string]string{
+		"app-20200427.log": "utc\nplus10\nutc same day\n",
+		"app-20200428.log": "plus10 next day\n",
+	} {
+		if got := readFile(t, filepath.Join(dir, name)); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestTimeRotatingFileSymlinkMatchingPattern(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	now := time.Date(2020, 4, 27, 12, 0, 0, 0, time.UTC)
+	r, err := NewTimeRotatingFile(filepath.Join(dir, "app-%Y%m%d.log"), UTC(),
+		Clock(func() time.Time { return now }), Symlink(filepath.Join(dir, "app-current.log")), MaxBackups(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"monday\n", "tuesday\n", "wednesday\n"} {
+		r.Write([]byte(s))
+		now = now.AddDate(0, 0, 1)
+	}
+	r.Close()
+	// The link matches the pattern but is not a backup.
+	if got := readFile(t, filepath.Join(dir, "app-20200428.log")); got != "tuesday\n" {
+		t.Errorf("backup: expected %q, got %q", "tuesday\n", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app-20200427.log")); !os.IsNotExist(err) {
+		t.Errorf("oldest file should have been removed, stat error: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package glog
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// modulePat is one pattern=N element of a vmodule spec.
+type modulePat struct {
+	pattern string
+	full    bool // match the tail of the path rather than the base name
+	level   int
+}
+
+// vmodule is a parsed vmodule spec. The verbosity it gives each call site
+// of V is cached by program counter.
+type vmodule struct {
+	spec   string
+	filter []modulePat
+	cache  sync.Map // uintptr -> int
+}
+
+// parseVModule parses a comma-separated list of pattern=N. A pattern is a
+// glob matched against the file name of the caller without ".go", or, if it
+// contains '/', against as many trailing elements of its path.
+func parseVModule(spec string) (*vmodule, error) {
+	m := &vmodule{spec: spec}
+	for _, pat := range strings.Split(spec, ",") {
+		if pat == "" {
+			continue
+		}
+		i := strings.LastIndexByte(pat, '=')
+		if i <= 0 {
+			return nil, errors.New("glog: syntax error in vmodule: expect comma-separated list of pattern=N")
+		}
+		v, err := strconv.Atoi(pat[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("glog: invalid level in vmodule %q: %v", pat, err)
+		}
+		pattern := strings.TrimSuffix(pat[:i], ".go")
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("glog: invalid pattern in vmodule %q: %v", pat, err)
+		}
+		m.filter = append(m.filter, modulePat{pattern: pattern, full: strings.Contains(pattern, "/"), level: v})
+	}
+	if len(m.filter) == 0 {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// levelAt returns the verbosity of the call site at pc: that of the first
+// matching pattern, or def.
+func (m *vmodule) levelAt(pc uintptr, def int) int {
+	if v, ok := m.cache.Load(pc); ok {
+		return v.(int)
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	file := strings.TrimSuffix(frame.File, ".go")
+	level := def
+	for _, f := range m.filter {
+		name := filepath.Base(file)
+		if f.full {
+			name = file
+			if n := strings.Count(f.pattern, "/") + 1; !strings.HasPrefix(f.pattern, "/") && n <= strings.Count(file, "/") {
+				parts := strings.Split(file, "/")
+				name = strings.Join(parts[len(parts)-n:], "/")
+			}
+		}
+		if ok, _ := filepath.Match(f.pattern, name); ok {
+			level = f.level
+			break
+		}
+	}
+	m.cache.Store(pc, level)
+	return level
+}
+
+// Verbose is returned by V. Its methods log at INFO when verbosity at the
+// call site of V was high enough and do nothing otherwise, so that
+//
+//	l.V(2).Info("hello")
+//
+// costs a comparison when verbosity is below 2.
+type Verbose struct {
+	l     *Logger // nil when disabled
+	depth int
+}
+
+// V returns a Verbose enabled when the verbosity of l is at least level,
+// either set by SetVerbosity or by the first vmodule pattern matching the
+// file calling V.
+func (l *Logger) V(level int) Verbose {
+	return l.vDepth(1, 0, level)
+}
+
+// VDepth is like V for packages wrapping a Logger: both the vmodule lookup
+// and the records of the returned Verbose are attributed to the caller depth
+// frames above the caller of VDepth.
+func (l *Logger) VDepth(depth int, level int) Verbose {
+	return l.vDepth(depth+1, depth, level)
+}
+
+// vDepth checks the call site skip frames above vDepth; logDepth is the
+// depth used by the methods of the returned Verbose.
+func (l *Logger) vDepth(skip, logDepth int, level int) Verbose {
+	v := int(atomic.LoadInt32(&l.verbosity))
+	if v >= level {
+		return Verbose{l: l, depth: logDepth}
+	}
+	m := l.vmodule.Load()
+	if m == nil {
+		return Verbose{}
+	}
+	var pcs [1]uintptr
+	if runtime.Callers(skip+2, pcs[:]) == 0 || m.levelAt(pcs[0], v) < level {
+		return Verbose{}
+	}
+	return Verbose{l: l, depth: logDepth}
+}
+
+// Enabled reports whether v logs.
+func (v Verbose) Enabled() bool {
+	return v.l != nil
+}
+
+func (v Verbose) Info(args ...interface{}) {
+	if v.l != nil {
+		v.l.logDepth(v.depth, INFO, args...)
+	}
+}
+
+func (v Verbose) Infof(format string, args ...interface{}) {
+	if v.l != nil {
+		v.l.logfDepth(v.depth, INFO, format, args...)
+	}
+}
+
+// Verbosity returns the verbosity level compared by V.
+func (l *Logger) Verbosity() int {
+	return int(atomic.LoadInt32(&l.verbosity))
+}
+
+// SetVerbosity sets the verbosity level compared by V.
+func (l *Logger) SetVerbosity(level int) {
+	atomic.StoreInt32(&l.verbosity, int32(level))
+}
+
+// VModule returns the vmodule spec set by SetVModule.
+func (l *Logger) VModule() string {
+	if m := l.vmodule.Load(); m != nil {
+		return m.spec
+	}
+	return ""
+}
+
+// SetVModule overrides the verbosity per source file with a comma-separated
+// list of pattern=N, such as "db*=3,http/server.go=2". A pattern without '/'
+// is a glob matched against the base name of the file calling V; one with
+// '/' is matched against as many trailing elements of its path. The first
+// matching pattern wins. An empty spec removes the overrides.
+func (l *Logger) SetVModule(spec string) error {
+	m, err := parseVModule(spec)
+	if err != nil {
+		return err
+	}
+	l.vmodule.Store(m)
+	return nil
+}
+
+// V reports whether the verbosity of the standard logger is at least level
+// at the call site.
+func V(level int) Verbose {
+	return glog.vDepth(1, -1, level)
+}
+
+// SetVerbosity sets the verbosity level of the standard logger.
+func SetVerbosity(level int) {
+	glog.SetVerbosity(level)
+}
+
+// SetVModule sets the vmodule spec of the standard logger.
+func SetVModule(spec string) error {
+	return glog.SetVModule(spec)
+}
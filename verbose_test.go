@@ -0,0 +1,97 @@
+package glog
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func vInfo(l *Logger, level int, s string) {
+	l.VDepth(1, level).Info(s)
+}
+
+func TestV(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lshortfile), WithVerbosity(1))
+	l.V(1).Info("v1")
+	l.V(2).Infof("%s", "v2")
+	if l.V(2).Enabled() {
+		t.Errorf("V(2) enabled at verbosity 1")
+	}
+	if got, want := buf.String(), "verbose_test.go:16: v1\n"; got != want {
+		t.Errorf("V: expected %q, got %q", want, got)
+	}
+}
+
+func TestVModule(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, WithFlags(Lshortfile), WithVModule("other=9,verbose_te*=3"))
+	l.V(3).Info("v3")
+	l.V(4).Info("v4")
+	vInfo(l, 3, "depth")
+	if err := l.SetVModule("*/verbose_test.go=2"); err != nil {
+		t.Fatal(err)
+	}
+	l.V(2).Info("path")
+	l.V(3).Info("v3 again")
+	if err := l.SetVModule(""); err != nil || l.VModule() != "" {
+		t.Fatalf("SetVModule: expected no spec, got %q (%v)", l.VModule(), err)
+	}
+	l.V(1).Info("cleared")
+	want := "verbose_test.go:29: v3\nverbose_test.go:31: depth\nverbose_test.go:35: path\n"
+	if got := buf.String(); got != want {
+		t.Errorf("vmodule: expected %q, got %q", want, got)
+	}
+}
+
+func TestVModuleSyntax(t *testing.T) {
+	l := New(Discard)
+	for _, bad := range []string{"noequals", "=1", "a=x", "[=1"} {
+		if err := l.SetVModule(bad); err == nil {
+			t.Errorf("vmodule %q: expected an error", bad)
+		}
+	}
+	if err := l.SetVModule("http/server.go=2,db*=3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.VModule(); got != "http/server.go=2,db*=3" {
+		t.Errorf("vmodule: unexpected spec %q", got)
+	}
+}
+
+func TestPackageV(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stderr)
+	flags := Flags()
+	SetFlags(Lshortfile)
+	defer SetFlags(flags)
+	level, depth := GetLevel(), CallDepth()
+	SetLevel(INFO)
+	defer SetLevel(level)
+	SetCallDepth(4)
+	defer SetCallDepth(depth)
+	SetVerbosity(2)
+	defer SetVerbosity(0)
+	V(2).Info("package")
+	V(3).Info("hidden")
+	if got, want := buf.String(), "verbose_test.go:76: package\n"; got != want {
+		t.Errorf("package V: expected %q, got %q", want, got)
+	}
+}
+
+func BenchmarkVDisabled(b *testing.B) {
+	l := New(Discard)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.V(1).Info("disabled")
+	}
+}
+
+func BenchmarkVModuleDisabled(b *testing.B) {
+	l := New(Discard, WithVModule("other=9"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.V(1).Info("disabled")
+	}
+}